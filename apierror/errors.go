@@ -0,0 +1,140 @@
+// Package apierror defines the error responses of the API. Every error
+// has a stable code clients can branch on, a message in the client's
+// language and, for invalid input, details per field. Causes such as
+// Supabase errors are logged but never sent to the client.
+package apierror
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+)
+
+// Error is an error response.
+type Error struct {
+	Status int
+	Code   string
+	// Fields lists what is wrong with the input, if anything.
+	Fields []FieldError
+	// Meta holds extra top-level response fields, e.g. retry_after.
+	Meta  map[string]any
+	cause error
+}
+
+// FieldError describes one invalid input field. Rule is the validation
+// rule that failed (e.g. "required", "oneof") and Param its argument.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// New returns an error response with the given status and code. The code
+// needs a message in the catalog (see messages.go).
+func New(status int, code string) *Error {
+	return &Error{Status: status, Code: code}
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %v", e.Code, e.cause)
+	}
+	return e.Code
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is matches errors with the same code, so errors.Is(err, ErrAccountNotFound)
+// holds for wrapped copies as well.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap returns a copy of e caused by err. The cause is logged with the
+// request, not returned to the client.
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.cause = err
+	return &c
+}
+
+// With returns a copy of e with an extra top-level response field.
+func (e *Error) With(key string, value any) *Error {
+	c := *e
+	c.Meta = maps.Clone(e.Meta)
+	if c.Meta == nil {
+		c.Meta = map[string]any{}
+	}
+	c.Meta[key] = value
+	return &c
+}
+
+// WithFields returns a copy of e listing invalid fields.
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	c := *e
+	c.Fields = append(append([]FieldError(nil), e.Fields...), fields...)
+	return &c
+}
+
+// Generic errors, also used by the central mapping in From.
+var (
+	ErrValidation  = New(http.StatusBadRequest, "VALIDATION_FAILED")
+	ErrConflict    = New(http.StatusConflict, "CONFLICT")
+	ErrRateLimited = New(http.StatusTooManyRequests, "RATE_LIMITED")
+	ErrInternal    = New(http.StatusInternalServerError, "INTERNAL_ERROR")
+	ErrUnavailable = New(http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE")
+	ErrTimeout     = New(http.StatusGatewayTimeout, "TIMEOUT")
+	ErrNotFound    = New(http.StatusNotFound, "NOT_FOUND")
+	ErrDisabled    = New(http.StatusServiceUnavailable, "FEATURE_UNAVAILABLE")
+)
+
+// Authentication errors.
+var (
+	ErrUnauthenticated       = New(http.StatusUnauthorized, "UNAUTHENTICATED")
+	ErrInvalidToken          = New(http.StatusUnauthorized, "INVALID_TOKEN")
+	ErrSessionRevoked        = New(http.StatusUnauthorized, "SESSION_REVOKED")
+	ErrSecondFactorRequired  = New(http.StatusUnauthorized, "SECOND_FACTOR_REQUIRED")
+	ErrInsufficientScope     = New(http.StatusForbidden, "INSUFFICIENT_SCOPE")
+	ErrInvalidCredentials    = New(http.StatusUnauthorized, "INVALID_CREDENTIALS")
+	ErrIncorrectPassword     = New(http.StatusUnauthorized, "INCORRECT_PASSWORD")
+	ErrEmailTaken            = New(http.StatusConflict, "EMAIL_TAKEN")
+	ErrInvalidRefreshToken   = New(http.StatusUnauthorized, "INVALID_REFRESH_TOKEN")
+	ErrRefreshTokenReused    = New(http.StatusUnauthorized, "REFRESH_TOKEN_REUSED")
+	ErrInvalidResetToken     = New(http.StatusBadRequest, "INVALID_RESET_TOKEN")
+	ErrInvalidVerifyToken    = New(http.StatusBadRequest, "INVALID_VERIFICATION_TOKEN")
+	ErrEmailAlreadyVerified  = New(http.StatusConflict, "EMAIL_ALREADY_VERIFIED")
+	ErrTooManyLoginAttempts  = New(http.StatusTooManyRequests, "TOO_MANY_LOGIN_ATTEMPTS")
+	ErrTooManyInvalidCodes   = New(http.StatusTooManyRequests, "TOO_MANY_INVALID_CODES")
+	ErrInvalidCode           = New(http.StatusUnauthorized, "INVALID_CODE")
+	ErrInvalidChallenge      = New(http.StatusUnauthorized, "INVALID_LOGIN_CHALLENGE")
+	ErrTwoFactorEnabled      = New(http.StatusConflict, "TWO_FACTOR_ALREADY_ENABLED")
+	ErrTwoFactorNotEnabled   = New(http.StatusBadRequest, "TWO_FACTOR_NOT_ENABLED")
+	ErrTwoFactorSetupMissing = New(http.StatusBadRequest, "TWO_FACTOR_SETUP_REQUIRED")
+)
+
+// Resource errors.
+var (
+	ErrUserNotFound            = New(http.StatusNotFound, "USER_NOT_FOUND")
+	ErrAccountNotFound         = New(http.StatusNotFound, "ACCOUNT_NOT_FOUND")
+	ErrCategoryNotFound        = New(http.StatusNotFound, "CATEGORY_NOT_FOUND")
+	ErrTransactionNotFound     = New(http.StatusNotFound, "TRANSACTION_NOT_FOUND")
+	ErrHouseholdNotFound       = New(http.StatusNotFound, "HOUSEHOLD_NOT_FOUND")
+	ErrMemberNotFound          = New(http.StatusNotFound, "MEMBER_NOT_FOUND")
+	ErrInvitationNotFound      = New(http.StatusNotFound, "INVITATION_NOT_FOUND")
+	ErrShareNotFound           = New(http.StatusNotFound, "SHARE_NOT_FOUND")
+	ErrSessionNotFound         = New(http.StatusNotFound, "SESSION_NOT_FOUND")
+	ErrAPITokenNotFound        = New(http.StatusNotFound, "API_TOKEN_NOT_FOUND")
+	ErrDeletionNotFound        = New(http.StatusNotFound, "ACCOUNT_DELETION_NOT_FOUND")
+	ErrReadOnlyAccount         = New(http.StatusForbidden, "ACCOUNT_READ_ONLY")
+	ErrRoleForbidden           = New(http.StatusForbidden, "HOUSEHOLD_ROLE_FORBIDDEN")
+	ErrLastOwner               = New(http.StatusConflict, "LAST_HOUSEHOLD_OWNER")
+	ErrAlreadyMember           = New(http.StatusConflict, "ALREADY_HOUSEHOLD_MEMBER")
+	ErrInvitationInvalid       = New(http.StatusNotFound, "INVITATION_INVALID")
+	ErrInvitationEmailMismatch = New(http.StatusForbidden, "INVITATION_EMAIL_MISMATCH")
+	ErrShareWithSelf           = New(http.StatusBadRequest, "CANNOT_SHARE_WITH_SELF")
+	ErrArchiveInvalid          = New(http.StatusUnprocessableEntity, "ARCHIVE_INVALID")
+)
@@ -0,0 +1,64 @@
+package apierror
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/lengzuo/supa/postgres"
+	"github.com/leo140803/finance-app-backend/db"
+)
+
+// statusCoder matches errors returned by the Supabase Auth client.
+type statusCoder interface {
+	StatusCode() int
+}
+
+// From maps any error to an error response. Timeouts and failures of
+// Supabase itself always get their own codes, even when a handler reported
+// them as something else (e.g. a failed lookup as "not found"). Other
+// *Error values are returned as they are, except internal errors, whose
+// cause is mapped by its Postgres error code. Anything unknown is an
+// INTERNAL_ERROR. The original error is kept as the cause.
+func From(err error) *Error {
+	switch {
+	case errors.Is(err, db.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout.Wrap(err)
+	case errors.Is(err, context.Canceled):
+		// The client has gone away and will not read the response
+		return ErrTimeout.Wrap(err)
+	}
+
+	var pgErr *postgres.Error
+	isPgErr := errors.As(err, &pgErr)
+	var sc statusCoder
+	switch {
+	case isPgErr && pgErr.HTTPStatusCode >= http.StatusInternalServerError:
+		return ErrUnavailable.Wrap(err)
+	case !isPgErr && errors.As(err, &sc) && sc.StatusCode() == http.StatusTooManyRequests:
+		return ErrRateLimited.Wrap(err)
+	case !isPgErr && errors.As(err, &sc) && sc.StatusCode() >= http.StatusInternalServerError:
+		return ErrUnavailable.Wrap(err)
+	}
+
+	var e *Error
+	if errors.As(err, &e) && e.Status != http.StatusInternalServerError {
+		return e
+	}
+
+	if isPgErr {
+		switch pgErr.Code {
+		case "23505": // unique_violation
+			return ErrConflict.Wrap(err)
+		case "23503", "23514", "22P02": // foreign key, check, invalid text (e.g. malformed uuid)
+			return ErrValidation.Wrap(err)
+		case "PGRST116": // no rows for a single-object request
+			return ErrNotFound.Wrap(err)
+		}
+	}
+
+	if e != nil {
+		return e
+	}
+	return ErrInternal.Wrap(err)
+}
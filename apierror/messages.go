@@ -0,0 +1,238 @@
+package apierror
+
+import (
+	"slices"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// languages lists the supported message languages, the default first.
+var languages = []language.Tag{language.English, language.Indonesian}
+
+var matcher = language.NewMatcher(languages)
+
+// Language picks the supported language that best matches an
+// Accept-Language header, English when nothing matches.
+func Language(acceptLanguage string) string {
+	tags, _, _ := language.ParseAcceptLanguage(acceptLanguage)
+	_, index, _ := matcher.Match(tags...)
+	return languages[index].String()
+}
+
+// messages holds the message of every error code per language.
+var messages = map[string]map[string]string{
+	"en": {
+		"VALIDATION_FAILED":   "The request is invalid",
+		"CONFLICT":            "The request conflicts with existing data",
+		"RATE_LIMITED":        "Too many requests, please try again later",
+		"INTERNAL_ERROR":      "Something went wrong, please try again later",
+		"SERVICE_UNAVAILABLE": "The service is temporarily unavailable, please try again later",
+		"TIMEOUT":             "Request timed out",
+		"NOT_FOUND":           "Not found",
+		"FEATURE_UNAVAILABLE": "This feature is not available",
+
+		"UNAUTHENTICATED":            "Authentication required",
+		"INVALID_TOKEN":              "Invalid or expired token",
+		"SESSION_REVOKED":            "Session has been revoked",
+		"SECOND_FACTOR_REQUIRED":     "Sign in with your two-factor code to use this session",
+		"INSUFFICIENT_SCOPE":         "API token does not allow this request",
+		"INVALID_CREDENTIALS":        "Invalid email or password",
+		"INCORRECT_PASSWORD":         "Password is incorrect",
+		"EMAIL_TAKEN":                "An account with this email already exists",
+		"INVALID_REFRESH_TOKEN":      "Invalid or expired refresh token",
+		"REFRESH_TOKEN_REUSED":       "Refresh token has already been used",
+		"INVALID_RESET_TOKEN":        "Invalid or expired reset token",
+		"INVALID_VERIFICATION_TOKEN": "Invalid or expired verification token",
+		"EMAIL_ALREADY_VERIFIED":     "Email is already verified",
+		"TOO_MANY_LOGIN_ATTEMPTS":    "Too many failed login attempts, please try again later",
+		"TOO_MANY_INVALID_CODES":     "Too many invalid codes, please try again later",
+		"INVALID_CODE":               "Invalid code",
+		"INVALID_LOGIN_CHALLENGE":    "Invalid or expired login challenge",
+		"TWO_FACTOR_ALREADY_ENABLED": "Two-factor authentication is already enabled",
+		"TWO_FACTOR_NOT_ENABLED":     "Two-factor authentication is not enabled",
+		"TWO_FACTOR_SETUP_REQUIRED":  "Start 2FA setup first",
+
+		"USER_NOT_FOUND":             "User not found",
+		"ACCOUNT_NOT_FOUND":          "Account not found",
+		"CATEGORY_NOT_FOUND":         "Category not found",
+		"TRANSACTION_NOT_FOUND":      "Transaction not found",
+		"HOUSEHOLD_NOT_FOUND":        "Household not found",
+		"MEMBER_NOT_FOUND":           "Member not found",
+		"INVITATION_NOT_FOUND":       "Invitation not found",
+		"SHARE_NOT_FOUND":            "Share not found",
+		"SESSION_NOT_FOUND":          "Session not found",
+		"API_TOKEN_NOT_FOUND":        "API token not found",
+		"ACCOUNT_DELETION_NOT_FOUND": "No account deletion scheduled",
+		"ACCOUNT_READ_ONLY":          "You only have read access to this account",
+		"HOUSEHOLD_ROLE_FORBIDDEN":   "Your role in this household does not allow this",
+		"LAST_HOUSEHOLD_OWNER":       "A household needs at least one owner",
+		"ALREADY_HOUSEHOLD_MEMBER":   "You are already a member of this household",
+		"INVITATION_INVALID":         "Invitation is invalid or has expired",
+		"INVITATION_EMAIL_MISMATCH":  "This invitation was sent to a different email address",
+		"CANNOT_SHARE_WITH_SELF":     "You cannot share an account with yourself",
+		"ARCHIVE_INVALID":            "The archive cannot be restored",
+	},
+	"id": {
+		"VALIDATION_FAILED":   "Permintaan tidak valid",
+		"CONFLICT":            "Permintaan bertentangan dengan data yang sudah ada",
+		"RATE_LIMITED":        "Terlalu banyak permintaan, silakan coba lagi nanti",
+		"INTERNAL_ERROR":      "Terjadi kesalahan, silakan coba lagi nanti",
+		"SERVICE_UNAVAILABLE": "Layanan sedang tidak tersedia, silakan coba lagi nanti",
+		"TIMEOUT":             "Waktu permintaan habis",
+		"NOT_FOUND":           "Tidak ditemukan",
+		"FEATURE_UNAVAILABLE": "Fitur ini tidak tersedia",
+
+		"UNAUTHENTICATED":            "Autentikasi diperlukan",
+		"INVALID_TOKEN":              "Token tidak valid atau sudah kedaluwarsa",
+		"SESSION_REVOKED":            "Sesi telah dicabut",
+		"SECOND_FACTOR_REQUIRED":     "Masuk dengan kode autentikasi dua faktor untuk memakai sesi ini",
+		"INSUFFICIENT_SCOPE":         "Token API tidak mengizinkan permintaan ini",
+		"INVALID_CREDENTIALS":        "Email atau kata sandi salah",
+		"INCORRECT_PASSWORD":         "Kata sandi salah",
+		"EMAIL_TAKEN":                "Akun dengan email ini sudah ada",
+		"INVALID_REFRESH_TOKEN":      "Refresh token tidak valid atau sudah kedaluwarsa",
+		"REFRESH_TOKEN_REUSED":       "Refresh token sudah pernah digunakan",
+		"INVALID_RESET_TOKEN":        "Token reset tidak valid atau sudah kedaluwarsa",
+		"INVALID_VERIFICATION_TOKEN": "Token verifikasi tidak valid atau sudah kedaluwarsa",
+		"EMAIL_ALREADY_VERIFIED":     "Email sudah terverifikasi",
+		"TOO_MANY_LOGIN_ATTEMPTS":    "Terlalu banyak percobaan login yang gagal, silakan coba lagi nanti",
+		"TOO_MANY_INVALID_CODES":     "Terlalu banyak kode yang salah, silakan coba lagi nanti",
+		"INVALID_CODE":               "Kode salah",
+		"INVALID_LOGIN_CHALLENGE":    "Tantangan login tidak valid atau sudah kedaluwarsa",
+		"TWO_FACTOR_ALREADY_ENABLED": "Autentikasi dua faktor sudah aktif",
+		"TWO_FACTOR_NOT_ENABLED":     "Autentikasi dua faktor belum aktif",
+		"TWO_FACTOR_SETUP_REQUIRED":  "Mulai pengaturan 2FA terlebih dahulu",
+
+		"USER_NOT_FOUND":             "Pengguna tidak ditemukan",
+		"ACCOUNT_NOT_FOUND":          "Akun tidak ditemukan",
+		"CATEGORY_NOT_FOUND":         "Kategori tidak ditemukan",
+		"TRANSACTION_NOT_FOUND":      "Transaksi tidak ditemukan",
+		"HOUSEHOLD_NOT_FOUND":        "Rumah tangga tidak ditemukan",
+		"MEMBER_NOT_FOUND":           "Anggota tidak ditemukan",
+		"INVITATION_NOT_FOUND":       "Undangan tidak ditemukan",
+		"SHARE_NOT_FOUND":            "Akses berbagi tidak ditemukan",
+		"SESSION_NOT_FOUND":          "Sesi tidak ditemukan",
+		"API_TOKEN_NOT_FOUND":        "Token API tidak ditemukan",
+		"ACCOUNT_DELETION_NOT_FOUND": "Tidak ada penghapusan akun yang dijadwalkan",
+		"ACCOUNT_READ_ONLY":          "Anda hanya memiliki akses baca ke akun ini",
+		"HOUSEHOLD_ROLE_FORBIDDEN":   "Peran Anda di rumah tangga ini tidak mengizinkan tindakan ini",
+		"LAST_HOUSEHOLD_OWNER":       "Rumah tangga harus memiliki setidaknya satu pemilik",
+		"ALREADY_HOUSEHOLD_MEMBER":   "Anda sudah menjadi anggota rumah tangga ini",
+		"INVITATION_INVALID":         "Undangan tidak valid atau sudah kedaluwarsa",
+		"INVITATION_EMAIL_MISMATCH":  "Undangan ini dikirim ke alamat email lain",
+		"CANNOT_SHARE_WITH_SELF":     "Anda tidak dapat berbagi akun dengan diri sendiri",
+		"ARCHIVE_INVALID":            "Arsip tidak dapat dipulihkan",
+	},
+}
+
+// ruleMessages describes failed validation rules per language. {param}
+// is replaced by the rule's argument.
+var ruleMessages = map[string]map[string]string{
+	"en": {
+		"required":           "is required",
+		"notblank":           "must not be blank",
+		"oneof":              "must be one of: {param}",
+		"gt":                 "must be greater than {param}",
+		"gte":                "must be at least {param}",
+		"lt":                 "must be less than {param}",
+		"lte":                "must be at most {param}",
+		"min":                "must be at least {param}",
+		"max":                "must be at most {param}",
+		"len":                "must have a length of {param}",
+		"email":              "must be a valid email address",
+		"uuid":               "must be a valid UUID",
+		"datetime":           "must be a date in the format {param}",
+		"iso4217":            "must be an ISO 4217 currency code",
+		"bcp47_language_tag": "must be a language tag such as en-US",
+		"timezone":           "must be an IANA time zone such as Asia/Jakarta",
+		"unique":             "must be unique",
+		"exists":             "refers to a record that does not exist",
+		"type":               "must be of type {param}",
+		"json":               "is not valid JSON",
+		"eq":                 "must be {param}",
+		"weak":               "is too weak",
+		"excluded_with":      "cannot be combined with {param}",
+		"gtefield":           "must not be before {param}",
+		"max_points":         "produces more than {param} points, use a larger interval",
+		"invalid":            "is invalid",
+	},
+	"id": {
+		"required":           "wajib diisi",
+		"notblank":           "tidak boleh kosong",
+		"oneof":              "harus salah satu dari: {param}",
+		"gt":                 "harus lebih besar dari {param}",
+		"gte":                "minimal {param}",
+		"lt":                 "harus lebih kecil dari {param}",
+		"lte":                "maksimal {param}",
+		"min":                "minimal {param}",
+		"max":                "maksimal {param}",
+		"len":                "harus memiliki panjang {param}",
+		"email":              "harus berupa alamat email yang valid",
+		"uuid":               "harus berupa UUID yang valid",
+		"datetime":           "harus berupa tanggal dengan format {param}",
+		"iso4217":            "harus berupa kode mata uang ISO 4217",
+		"bcp47_language_tag": "harus berupa kode bahasa seperti id-ID",
+		"timezone":           "harus berupa zona waktu IANA seperti Asia/Jakarta",
+		"unique":             "harus unik",
+		"exists":             "merujuk ke data yang tidak ada",
+		"type":               "harus bertipe {param}",
+		"json":               "bukan JSON yang valid",
+		"eq":                 "harus {param}",
+		"weak":               "terlalu lemah",
+		"excluded_with":      "tidak dapat digabung dengan {param}",
+		"gtefield":           "tidak boleh sebelum {param}",
+		"max_points":         "menghasilkan lebih dari {param} titik, gunakan interval yang lebih besar",
+		"invalid":            "tidak valid",
+	},
+}
+
+// Message returns the message for code in lang, falling back to English
+// and then to the code itself.
+func Message(lang, code string) string {
+	if m, ok := messages[lang][code]; ok {
+		return m
+	}
+	if m, ok := messages["en"][code]; ok {
+		return m
+	}
+	return code
+}
+
+// Codes lists every error code, sorted.
+func Codes() []string {
+	codes := make([]string, 0, len(messages["en"]))
+	for code := range messages["en"] {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	return codes
+}
+
+// fieldMessage describes f in lang, e.g. "amount must be greater than 0".
+func fieldMessage(lang string, f FieldError) string {
+	m, ok := ruleMessages[lang][f.Rule]
+	if !ok {
+		m, ok = ruleMessages["en"][f.Rule]
+	}
+	if !ok {
+		m = ruleMessages[lang]["invalid"]
+	}
+	m = strings.ReplaceAll(m, "{param}", displayParam(f.Rule, f.Param))
+	if f.Field == "" {
+		return m
+	}
+	return f.Field + " " + m
+}
+
+// displayParam makes rule arguments readable, e.g. the Go date layout
+// 2006-01-02 becomes YYYY-MM-DD.
+func displayParam(rule, param string) string {
+	switch rule {
+	case "datetime":
+		return strings.NewReplacer("2006", "YYYY", "01", "MM", "02", "DD").Replace(param)
+	case "oneof", "excluded_with":
+		return strings.Join(strings.Fields(param), ", ")
+	}
+	return param
+}
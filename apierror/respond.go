@@ -0,0 +1,44 @@
+package apierror
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Respond writes err as an error response and aborts the request. The
+// cause is attached to the request so the request log line shows it.
+func Respond(c *gin.Context, err error) {
+	e := From(err)
+	if e.cause != nil {
+		c.Error(e.cause)
+	}
+	c.AbortWithStatusJSON(e.Status, Body(c, e))
+}
+
+// Body renders e in the language the client asked for:
+//
+//	{"error": "Account not found", "code": "ACCOUNT_NOT_FOUND", "request_id": "..."}
+//
+// Invalid input adds "details" with one entry per field.
+func Body(c *gin.Context, e *Error) gin.H {
+	lang := Language(c.GetHeader("Accept-Language"))
+	c.Header("Content-Language", lang)
+
+	body := gin.H{}
+	for k, v := range e.Meta {
+		body[k] = v
+	}
+	body["error"] = Message(lang, e.Code)
+	body["code"] = e.Code
+	if len(e.Fields) > 0 {
+		details := make([]FieldError, len(e.Fields))
+		for i, f := range e.Fields {
+			f.Message = fieldMessage(lang, f)
+			details[i] = f
+		}
+		body["details"] = details
+	}
+	if id := c.GetString("request_id"); id != "" {
+		body["request_id"] = id
+	}
+	return body
+}
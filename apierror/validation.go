@@ -0,0 +1,80 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-playground/validator/v10/non-standard/validators"
+)
+
+func init() {
+	// Report fields by the names clients send (json, or form for query
+	// parameters) instead of Go field names.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(fieldName)
+		// notblank rejects strings made of whitespace only
+		v.RegisterValidation("notblank", validators.NotBlank)
+	}
+}
+
+func fieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// Invalid turns an error from binding a request body or query into a
+// VALIDATION_FAILED response listing the offending fields.
+func Invalid(err error) *Error {
+	e := ErrValidation.Wrap(err)
+
+	var verrs validator.ValidationErrors
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &verrs):
+		for _, fe := range verrs {
+			e.Fields = append(e.Fields, FieldError{Field: namespace(fe), Rule: rule(fe.Tag()), Param: fe.Param()})
+		}
+	case errors.As(err, &syntaxErr):
+		e.Fields = []FieldError{{Field: "body", Rule: "json"}}
+	case errors.As(err, &typeErr):
+		e.Fields = []FieldError{{Field: typeErr.Field, Rule: "type", Param: typeErr.Type.Kind().String()}}
+	case errors.Is(err, io.EOF):
+		e.Fields = []FieldError{{Field: "body", Rule: "required"}}
+	}
+	return e
+}
+
+// namespace returns the path of a field without the top-level struct,
+// e.g. "items[0].amount".
+func namespace(fe validator.FieldError) string {
+	_, path, found := strings.Cut(fe.Namespace(), ".")
+	if !found {
+		return fe.Field()
+	}
+	return path
+}
+
+// rule folds validator tags that mean the same to clients.
+func rule(tag string) string {
+	switch tag {
+	case "uuid4", "uuid_rfc4122", "uuid4_rfc4122":
+		return "uuid"
+	case "required_without", "required_with", "required_if":
+		return "required"
+	}
+	return tag
+}
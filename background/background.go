@@ -0,0 +1,36 @@
+// Package background tracks fire-and-forget work started while handling
+// requests, such as last-seen updates, so shutdown can wait for it instead
+// of dropping the writes.
+package background
+
+import (
+	"context"
+	"sync"
+)
+
+var wg sync.WaitGroup
+
+// Go runs fn in its own goroutine.
+func Go(fn func()) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		fn()
+	}()
+}
+
+// Wait blocks until every function started with Go has returned, or ctx
+// is done.
+func Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
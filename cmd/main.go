@@ -1,22 +1,105 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/leo140803/finance-app-backend/background"
 	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/jobs"
+	"github.com/leo140803/finance-app-backend/logging"
 	"github.com/leo140803/finance-app-backend/routes"
+	"github.com/leo140803/finance-app-backend/tracing"
 )
 
 func main() {
+	printConfig := flag.Bool("print-config", false, "print the configuration with secrets redacted and exit")
+	flag.Parse()
+
 	godotenv.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		fmt.Print(cfg)
+		return
+	}
+	logging.Init(cfg.Log.Level, cfg.Log.Format)
+	slog.Info("configuration loaded", "config", cfg)
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing.Exporter, cfg.Tracing.ServiceName)
+	if err != nil {
+		slog.Error("failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
+	config.Init(cfg)
+
+	scheduler := jobs.NewScheduler(jobs.AccountDeletion(), jobs.UsedRefreshTokenCleanup())
+	scheduler.Start()
+
+	port := config.Server.Port
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           routes.SetupRouter(scheduler),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("server starting", "port", port)
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	// Render and most orchestrators send SIGTERM before killing the process
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	config.InitDB()
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("server failed", "error", err)
+			os.Exit(1)
+		}
+	case <-signals.Done():
+		slog.Info("shutting down", "timeout", config.Timeouts.Shutdown.String())
+	}
 
-	r := routes.SetupRouter()
-	port := os.Getenv("PORT")
+	ctx := context.Background()
+	if config.Timeouts.Shutdown > 0 {
+		var cancelShutdown context.CancelFunc
+		ctx, cancelShutdown = context.WithTimeout(ctx, config.Timeouts.Shutdown)
+		defer cancelShutdown()
+	}
 
-	log.Println("Server running on port " + port)
-	r.Run(":" + port)
+	// Stop taking requests and let in-flight ones, such as balance
+	// updates, finish first, then the jobs and detached writes they left
+	// behind, and flush the traces of all of it last.
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("requests did not finish before shutdown", "error", err)
+	}
+	if err := scheduler.Stop(ctx); err != nil {
+		slog.Error("jobs did not finish before shutdown", "error", err)
+	}
+	if err := background.Wait(ctx); err != nil {
+		slog.Error("background work did not finish before shutdown", "error", err)
+	}
+	// Traces are flushed even when draining used up the whole timeout
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("failed to flush traces", "error", err)
+	}
+	slog.Info("server stopped")
 }
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/lengzuo/supa/dto"
+)
+
+// AuthAdmin calls the Supabase Auth admin API, which the supa client does
+// not cover. It needs the service role key and is nil when that key is not
+// configured.
+var AuthAdmin *AuthAdminClient
+
+// ErrAdminUnavailable is returned by features that need AuthAdmin when no
+// service role key is configured.
+var ErrAdminUnavailable = errors.New("SUPABASE_SERVICE_ROLE_KEY is not configured")
+
+type AuthAdminClient struct {
+	baseURL    string
+	serviceKey string
+	httpClient *http.Client
+}
+
+// GeneratedLink is the part of the generate_link response the API uses.
+// HashedToken is what /verify expects as token_hash.
+type GeneratedLink struct {
+	UserID           string `json:"id"`
+	Email            string `json:"email"`
+	EmailConfirmedAt string `json:"email_confirmed_at"`
+	ActionLink       string `json:"action_link"`
+	HashedToken      string `json:"hashed_token"`
+	VerificationType string `json:"verification_type"`
+}
+
+func initAuthAdmin(key string) {
+	if key == "" {
+		slog.Warn("SUPABASE_SERVICE_ROLE_KEY not set, password reset, email verification and account deletion are disabled")
+		return
+	}
+	AuthAdmin = &AuthAdminClient{
+		baseURL:    authURL(),
+		serviceKey: key,
+		httpClient: &http.Client{Timeout: 20 * time.Second},
+	}
+}
+
+// GenerateLink creates a verification token of the given type ("recovery",
+// "magiclink", ...) for email without Supabase sending any mail, so the
+// API can deliver it through its own mailer.
+func (a *AuthAdminClient) GenerateLink(ctx context.Context, linkType, email string) (*GeneratedLink, error) {
+	var link GeneratedLink
+	body := map[string]string{"type": linkType, "email": email}
+	if err := a.do(ctx, "admin_generate_link", http.MethodPost, "/admin/generate_link", body, &link); err != nil {
+		return nil, err
+	}
+	if link.Email == "" {
+		link.Email = email
+	}
+	return &link, nil
+}
+
+// DeleteUser removes a user from Supabase Auth. A user that no longer
+// exists is not an error.
+func (a *AuthAdminClient) DeleteUser(ctx context.Context, authUserID string) error {
+	err := a.do(ctx, "admin_delete_user", http.MethodDelete, "/admin/users/"+url.PathEscape(authUserID), nil, nil)
+	if errors.Is(err, errAdminNotFound) {
+		return nil
+	}
+	return err
+}
+
+// SignOutUser ends every Supabase Auth session of the user with email, so
+// none of their refresh tokens can be used again. The admin API has no
+// call for this, so it opens a session through a generated magic link and
+// signs that out with global scope. Unconfirmed users cannot have sessions
+// and are skipped, as verifying the link would confirm their address.
+func (a *AuthAdminClient) SignOutUser(ctx context.Context, email string) error {
+	link, err := a.GenerateLink(ctx, "magiclink", email)
+	if err != nil {
+		return err
+	}
+	if link.EmailConfirmedAt == "" {
+		return nil
+	}
+	session, err := SupaClient.Auth.Verify(ctx, dto.VerifyRequest{Type: "magiclink", TokenHash: link.HashedToken})
+	if err != nil {
+		return fmt.Errorf("open session: %w", err)
+	}
+	return SignOut(ctx, session.AccessToken, "global")
+}
+
+// SignOut ends Supabase Auth sessions of the user an access token belongs
+// to: "local" ends the token's own session, "others" every other one and
+// "global" all of them. Their refresh tokens stop working; access tokens
+// already issued stay valid until they expire, which is why the sessions
+// table marks them revoked as well.
+func SignOut(ctx context.Context, accessToken, scope string) error {
+	return authRequest(ctx, "logout", authHTTPClient, http.MethodPost, authURL()+"/logout?scope="+url.QueryEscape(scope), anonKey, accessToken, nil, nil)
+}
+
+// authHTTPClient makes the Supabase Auth calls that need no service role
+// key.
+var authHTTPClient = &http.Client{Timeout: 20 * time.Second}
+
+func authURL() string {
+	return fmt.Sprintf("https://%s.supabase.co/auth/v1", ProjectID)
+}
+
+var errAdminNotFound = errors.New("not found")
+
+func (a *AuthAdminClient) do(ctx context.Context, operation, method, path string, body, out any) error {
+	return authRequest(ctx, operation, a.httpClient, method, a.baseURL+path, a.serviceKey, a.serviceKey, body, out)
+}
+
+// authRequest calls Supabase Auth with apiKey and a bearer token, which is
+// the service role key for the admin API and a user's access token for
+// calls made on their behalf. operation names the call in the metrics.
+func authRequest(ctx context.Context, operation string, client *http.Client, method, target, apiKey, bearer string, body, out any) error {
+	return measureAuth(ctx, operation, func(ctx context.Context) error {
+		return sendAuthRequest(ctx, client, method, target, apiKey, bearer, body, out)
+	})
+}
+
+func sendAuthRequest(ctx context.Context, client *http.Client, method, target, apiKey, bearer string, body, out any) error {
+	path := target
+	if u, err := url.Parse(target); err == nil {
+		path = u.Path
+	}
+
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("apikey", apiKey)
+	req.Header.Set("Authorization", "Bearer "+bearer)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("auth %s %s: %w", method, path, errAdminNotFound)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("auth %s %s: status %d: %s", method, path, resp.StatusCode, msg)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
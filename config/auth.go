@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// AuthConfig controls how AuthMiddleware verifies access tokens.
+type AuthConfig struct {
+	// JWTSecret verifies HS256 tokens (Supabase "JWT Secret").
+	JWTSecret string `yaml:"jwt_secret" env:"SUPABASE_JWT_SECRET" secret:"true"`
+	// JWKSURL points at the project's JSON Web Key Set for asymmetric
+	// (ES256/RS256) signing keys.
+	JWKSURL  string `yaml:"jwks_url" env:"SUPABASE_JWKS_URL" validate:"omitempty,url"`
+	Audience string `yaml:"audience" env:"JWT_AUDIENCE" default:"authenticated" validate:"required"`
+	// Issuer defaults to the project's Supabase Auth URL.
+	Issuer string `yaml:"issuer" env:"JWT_ISSUER" validate:"omitempty,url"`
+	// RemoteFallback asks Supabase Auth to validate a token whenever it
+	// cannot be verified locally, e.g. an unknown key ID.
+	RemoteFallback bool `yaml:"remote_fallback" env:"AUTH_REMOTE_FALLBACK" default:"false"`
+	// UserCacheTTL is how long the auth user to users.id mapping is kept.
+	UserCacheTTL time.Duration `yaml:"user_cache_ttl" env:"AUTH_USER_CACHE_TTL" default:"10m" validate:"gte=0"`
+	// DeletionGraceDays is how many days an account deletion can still be
+	// cancelled before its data is erased.
+	DeletionGraceDays int `yaml:"deletion_grace_days" env:"ACCOUNT_DELETION_GRACE_DAYS" default:"14" validate:"gte=0"`
+	// DeletionGracePeriod is DeletionGraceDays as a duration, set by
+	// InitAuth.
+	DeletionGracePeriod time.Duration `yaml:"-"`
+	// UsedRefreshTokenDays is how long exchanged refresh tokens are kept
+	// to detect their reuse. Supabase Auth refuses older ones as well, but
+	// without signing the user out everywhere.
+	UsedRefreshTokenDays int `yaml:"used_refresh_token_days" env:"USED_REFRESH_TOKEN_DAYS" default:"30" validate:"gte=1"`
+	// EncryptionKey encrypts 2FA secrets and the tokens of logins waiting
+	// for their second factor. Changing it makes those unreadable.
+	EncryptionKey string `yaml:"encryption_key" env:"ENCRYPTION_KEY" secret:"true" validate:"required,min=32"`
+}
+
+var Auth AuthConfig
+
+// LocalVerification reports whether any key material for verifying tokens
+// without a Supabase round-trip is configured.
+func (a AuthConfig) LocalVerification() bool {
+	return a.JWTSecret != "" || a.JWKSURL != ""
+}
+
+// InitAuth applies the token verification settings. It must run after
+// InitDB because the default issuer is derived from the project ID.
+func InitAuth(cfg AuthConfig) {
+	Auth = cfg
+	Auth.JWKSURL = strings.TrimSpace(Auth.JWKSURL)
+	if Auth.Issuer == "" {
+		Auth.Issuer = fmt.Sprintf("https://%s.supabase.co/auth/v1", ProjectID)
+	}
+	Auth.DeletionGracePeriod = time.Duration(Auth.DeletionGraceDays) * 24 * time.Hour
+	initEncryption(Auth.EncryptionKey)
+
+	if !Auth.LocalVerification() {
+		// Keep existing deployments working: without keys every token
+		// has to be checked by Supabase.
+		Auth.RemoteFallback = true
+		slog.Warn("SUPABASE_JWT_SECRET / SUPABASE_JWKS_URL not set, verifying tokens with Supabase Auth")
+		return
+	}
+	slog.Info("verifying tokens locally", "issuer", Auth.Issuer, "audience", Auth.Audience)
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/lengzuo/supa/dto"
+	"github.com/leo140803/finance-app-backend/metrics"
+	"github.com/leo140803/finance-app-backend/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// authTable labels Supabase Auth calls in the storage metrics, next to
+// the database tables.
+const authTable = "auth"
+
+// measureAuth runs a Supabase Auth call in its own span and records it
+// like db.Query.Execute records database requests. A missing user is an
+// answer, not a failure.
+func measureAuth(ctx context.Context, operation string, call func(context.Context) error) error {
+	ctx, span := tracing.Tracer().Start(ctx, authTable+" "+operation, trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+
+	start := time.Now()
+	err := call(ctx)
+
+	metrics.StorageDuration.WithLabelValues(authTable, operation).Observe(time.Since(start).Seconds())
+	if err != nil && !errors.Is(err, errAdminNotFound) {
+		metrics.StorageErrors.WithLabelValues(authTable, operation).Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+// supaAuth is the method set of the supa client's Auth field.
+type supaAuth interface {
+	ResetPasswordForEmail(ctx context.Context, body dto.ResetPasswordForEmailRequest) error
+	RefreshToken(ctx context.Context, refreshToken string) (*dto.AuthDetailResp, error)
+	SignInWithIDToken(ctx context.Context, body dto.SignInWithIDTokenRequest) (*dto.AuthDetailResp, error)
+	SignInWithOAuth(ctx context.Context, body dto.OAuthSignInRequest) (string, error)
+	SignInWithOTP(ctx context.Context, body dto.SignInRequest) error
+	SignInWithPassword(ctx context.Context, body dto.SignInRequest) (*dto.AuthDetailResp, error)
+	SignOut(ctx context.Context, token string) error
+	SignUp(ctx context.Context, credentials dto.SignUpRequest) (*dto.AuthDetailResp, error)
+	User(ctx context.Context, token string) (*dto.User, error)
+	UpdateUser(ctx context.Context, token string, body dto.UpdateUserRequest) (*dto.User, error)
+	Verify(ctx context.Context, body dto.VerifyRequest) (*dto.AuthDetailResp, error)
+}
+
+// measuredAuth replaces SupaClient.Auth so every call is measured.
+type measuredAuth struct {
+	auth supaAuth
+}
+
+// measure runs call through measureAuth and returns its result.
+func measure[T any](ctx context.Context, operation string, call func(context.Context) (T, error)) (T, error) {
+	var result T
+	err := measureAuth(ctx, operation, func(ctx context.Context) error {
+		var err error
+		result, err = call(ctx)
+		return err
+	})
+	return result, err
+}
+
+func (a measuredAuth) ResetPasswordForEmail(ctx context.Context, body dto.ResetPasswordForEmailRequest) error {
+	return measureAuth(ctx, "reset_password_for_email", func(ctx context.Context) error {
+		return a.auth.ResetPasswordForEmail(ctx, body)
+	})
+}
+
+func (a measuredAuth) RefreshToken(ctx context.Context, refreshToken string) (*dto.AuthDetailResp, error) {
+	return measure(ctx, "refresh_token", func(ctx context.Context) (*dto.AuthDetailResp, error) {
+		return a.auth.RefreshToken(ctx, refreshToken)
+	})
+}
+
+func (a measuredAuth) SignInWithIDToken(ctx context.Context, body dto.SignInWithIDTokenRequest) (*dto.AuthDetailResp, error) {
+	return measure(ctx, "sign_in_with_id_token", func(ctx context.Context) (*dto.AuthDetailResp, error) {
+		return a.auth.SignInWithIDToken(ctx, body)
+	})
+}
+
+func (a measuredAuth) SignInWithOAuth(ctx context.Context, body dto.OAuthSignInRequest) (string, error) {
+	return measure(ctx, "sign_in_with_oauth", func(ctx context.Context) (string, error) {
+		return a.auth.SignInWithOAuth(ctx, body)
+	})
+}
+
+func (a measuredAuth) SignInWithOTP(ctx context.Context, body dto.SignInRequest) error {
+	return measureAuth(ctx, "sign_in_with_otp", func(ctx context.Context) error {
+		return a.auth.SignInWithOTP(ctx, body)
+	})
+}
+
+func (a measuredAuth) SignInWithPassword(ctx context.Context, body dto.SignInRequest) (*dto.AuthDetailResp, error) {
+	return measure(ctx, "sign_in_with_password", func(ctx context.Context) (*dto.AuthDetailResp, error) {
+		return a.auth.SignInWithPassword(ctx, body)
+	})
+}
+
+func (a measuredAuth) SignOut(ctx context.Context, token string) error {
+	return measureAuth(ctx, "sign_out", func(ctx context.Context) error {
+		return a.auth.SignOut(ctx, token)
+	})
+}
+
+func (a measuredAuth) SignUp(ctx context.Context, credentials dto.SignUpRequest) (*dto.AuthDetailResp, error) {
+	return measure(ctx, "sign_up", func(ctx context.Context) (*dto.AuthDetailResp, error) {
+		return a.auth.SignUp(ctx, credentials)
+	})
+}
+
+func (a measuredAuth) User(ctx context.Context, token string) (*dto.User, error) {
+	return measure(ctx, "user", func(ctx context.Context) (*dto.User, error) {
+		return a.auth.User(ctx, token)
+	})
+}
+
+func (a measuredAuth) UpdateUser(ctx context.Context, token string, body dto.UpdateUserRequest) (*dto.User, error) {
+	return measure(ctx, "update_user", func(ctx context.Context) (*dto.User, error) {
+		return a.auth.UpdateUser(ctx, token, body)
+	})
+}
+
+func (a measuredAuth) Verify(ctx context.Context, body dto.VerifyRequest) (*dto.AuthDetailResp, error) {
+	return measure(ctx, "verify", func(ctx context.Context) (*dto.AuthDetailResp, error) {
+		return a.auth.Verify(ctx, body)
+	})
+}
@@ -2,21 +2,52 @@ package config
 
 import (
 	"log"
-	"os"
+	"log/slog"
 	"strings"
 
 	supabase "github.com/lengzuo/supa"
+	"github.com/leo140803/finance-app-backend/db"
 )
 
+// SupaClient is the Supabase client. Its Auth calls are measured like
+// the database requests made through DB.
 var SupaClient *supabase.Client
 
-func InitDB() {
-	supabaseProjectID := os.Getenv("SUPABASE_PROJECT_ID")
-	supabaseKey := os.Getenv("SUPABASE_ANON_KEY")
+// DB is the database client handlers use. It wraps SupaClient.DB so every
+// query is measured.
+var DB *db.Client
 
-	if supabaseProjectID == "" || supabaseKey == "" {
-		log.Fatal("SUPABASE_PROJECT_ID and SUPABASE_ANON_KEY environment variables are required")
-	}
+// ProjectID is the cleaned Supabase project reference used by InitDB.
+var ProjectID string
+
+// anonKey is the project's public API key, sent with Supabase Auth calls
+// made on behalf of a user.
+var anonKey string
+
+// Server holds the listen port and allowed CORS origins.
+var Server ServerConfig
+
+// Features tells which optional parts of the API are switched on.
+var Features FeatureConfig
+
+// Metrics holds the token protecting /metrics.
+var Metrics MetricsConfig
+
+// Init sets up every shared client from cfg.
+func Init(cfg *Config) {
+	Server = cfg.Server
+	Features = cfg.Features
+	Metrics = cfg.Metrics
+	InitDB(cfg.Supabase)
+	InitTimeouts(cfg.Timeouts)
+	InitAuth(cfg.Auth)
+	InitMailer(cfg.Mail)
+	InitRateLimit(cfg.RateLimit)
+}
+
+func InitDB(cfg SupabaseConfig) {
+	supabaseProjectID := cfg.ProjectID
+	supabaseKey := cfg.AnonKey
 
 	// Clean the project ID (remove any URL parts if accidentally included)
 	supabaseProjectID = strings.TrimSpace(supabaseProjectID)
@@ -28,7 +59,7 @@ func InitDB() {
 		}
 	}
 
-	log.Printf("Connecting to Supabase project: %s", supabaseProjectID)
+	slog.Info("connecting to Supabase", "project_id", supabaseProjectID)
 
 	client, err := supabase.New(supabase.Config{
 		ApiKey:     supabaseKey,
@@ -38,6 +69,11 @@ func InitDB() {
 		log.Fatal("Failed to create Supabase client:", err)
 	}
 
+	client.Auth = measuredAuth{auth: client.Auth}
 	SupaClient = client
-	log.Println("Supabase connected 🚀")
+	DB = db.New(client.DB)
+	ProjectID = supabaseProjectID
+	anonKey = supabaseKey
+	initAuthAdmin(cfg.ServiceRoleKey)
+	slog.Info("Supabase connected")
 }
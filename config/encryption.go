@@ -0,0 +1,67 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// encryptedPrefix marks values written by Encrypt. Values without it were
+// stored before encryption and are returned by Decrypt as they are.
+const encryptedPrefix = "enc:v1:"
+
+var aead cipher.AEAD
+
+// initEncryption derives the AES-256 key from the configured secret.
+func initEncryption(secret string) {
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		panic(err)
+	}
+	aead, err = cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Encrypt seals a secret, such as a TOTP secret or a parked token, with
+// AES-GCM before it is stored.
+func Encrypt(plaintext string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value written by Encrypt.
+func Decrypt(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return value, nil
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("encrypted value is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Encrypted reports whether value was written by Encrypt.
+func Encrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
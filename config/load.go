@@ -0,0 +1,277 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/leo140803/finance-app-backend/logging"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is everything the service can be configured with. Every setting
+// has an environment variable (the env tag) and a key in the optional YAML
+// file named by CONFIG_FILE (the yaml tag). Environment variables win over
+// the file, and the file over the defaults.
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Supabase  SupabaseConfig  `yaml:"supabase"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Mail      MailConfig      `yaml:"mail"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	Log       LogConfig       `yaml:"log"`
+	Timeouts  TimeoutConfig   `yaml:"timeouts"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Features  FeatureConfig   `yaml:"features"`
+}
+
+type ServerConfig struct {
+	Port string `yaml:"port" env:"PORT" default:"8080" validate:"required,numeric"`
+	// CORSOrigins lists the frontends allowed to call the API from a
+	// browser.
+	CORSOrigins []string `yaml:"cors_origins" env:"CORS_ORIGINS" default:"http://localhost:3000,http://127.0.0.1:3000,http://localhost:3001,https://fe-duitku-git-main-leonardo-nickholas-andriantos-projects.vercel.app" validate:"min=1,dive,required"`
+	// TrustedProxies lists the addresses (IPs or CIDRs) of the load
+	// balancers in front of the service. Only their X-Forwarded-For
+	// entries are believed; without any the connection's address is the
+	// client IP used for rate limits.
+	TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES" validate:"dive,cidr|ip"`
+}
+
+type SupabaseConfig struct {
+	ProjectID string `yaml:"project_id" env:"SUPABASE_PROJECT_ID" validate:"required"`
+	AnonKey   string `yaml:"anon_key" env:"SUPABASE_ANON_KEY" secret:"true" validate:"required"`
+	// ServiceRoleKey enables the Auth admin API (password reset, email
+	// verification and account deletion).
+	ServiceRoleKey string `yaml:"service_role_key" env:"SUPABASE_SERVICE_ROLE_KEY" secret:"true"`
+}
+
+type MailConfig struct {
+	// Driver is "file" (writes .eml files to Dir, for local development)
+	// or "smtp".
+	Driver       string `yaml:"driver" env:"MAIL_DRIVER" default:"file" validate:"oneof=file smtp"`
+	Dir          string `yaml:"dir" env:"MAIL_DIR" default:"mail"`
+	From         string `yaml:"from" env:"MAIL_FROM" default:"Duitku <no-reply@duitku.local>" validate:"required"`
+	SMTPHost     string `yaml:"smtp_host" env:"SMTP_HOST" default:"localhost"`
+	SMTPPort     int    `yaml:"smtp_port" env:"SMTP_PORT" default:"25" validate:"min=1,max=65535"`
+	SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+	// AppURL is the frontend base URL used in links sent by email.
+	AppURL string `yaml:"app_url" env:"APP_URL" default:"http://localhost:3000" validate:"url"`
+}
+
+type RateLimitConfig struct {
+	// Store is "memory" (single instance) or "supabase" (shared between
+	// instances).
+	Store string `yaml:"store" env:"RATE_LIMIT_STORE" default:"memory" validate:"oneof=memory supabase"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" default:"info" validate:"oneof=debug info warn error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" default:"json" validate:"oneof=json text"`
+}
+
+type MetricsConfig struct {
+	// Token is the bearer token required to scrape /metrics. Without one
+	// the endpoint is open.
+	Token string `yaml:"token" env:"METRICS_TOKEN" secret:"true"`
+}
+
+type TracingConfig struct {
+	Exporter    string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER" default:"none" validate:"oneof=none otlp stdout"`
+	ServiceName string `yaml:"service_name" env:"OTEL_SERVICE_NAME" default:"duitku-backend" validate:"required"`
+}
+
+// FeatureConfig switches optional parts of the API off. Disabled endpoints
+// respond 503 FEATURE_UNAVAILABLE.
+type FeatureConfig struct {
+	Registration bool `yaml:"registration" env:"FEATURE_REGISTRATION" default:"true"`
+	// TwoFactor only covers setting up 2FA; users who already enabled it
+	// keep being asked for their code.
+	TwoFactor      bool `yaml:"two_factor" env:"FEATURE_TWO_FACTOR" default:"true"`
+	Households     bool `yaml:"households" env:"FEATURE_HOUSEHOLDS" default:"true"`
+	AccountSharing bool `yaml:"account_sharing" env:"FEATURE_ACCOUNT_SHARING" default:"true"`
+	APITokens      bool `yaml:"api_tokens" env:"FEATURE_API_TOKENS" default:"true"`
+	Export         bool `yaml:"export" env:"FEATURE_EXPORT" default:"true"`
+	Import         bool `yaml:"import" env:"FEATURE_IMPORT" default:"true"`
+	Metrics        bool `yaml:"metrics" env:"FEATURE_METRICS" default:"true"`
+}
+
+// Load reads the configuration from the defaults, the YAML file named by
+// CONFIG_FILE if set, and the environment, and validates it.
+func Load() (*Config, error) {
+	var cfg Config
+	if err := walk(reflect.ValueOf(&cfg).Elem(), func(f reflect.Value, sf reflect.StructField) error {
+		if def, ok := sf.Tag.Lookup("default"); ok {
+			return setField(f, def)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		dec := yaml.NewDecoder(file)
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if err := walk(reflect.ValueOf(&cfg).Elem(), func(f reflect.Value, sf reflect.StructField) error {
+		key := sf.Tag.Get("env")
+		if v := os.Getenv(key); key != "" && v != "" {
+			if err := setField(f, v); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &cfg, cfg.validate()
+}
+
+// walk calls fn for every setting in v, descending into sections.
+func walk(v reflect.Value, fn func(reflect.Value, reflect.StructField) error) error {
+	for i := 0; i < v.NumField(); i++ {
+		f, sf := v.Field(i), v.Type().Field(i)
+		if sf.Tag.Get("yaml") == "-" {
+			continue
+		}
+		if f.Kind() == reflect.Struct {
+			if err := walk(f, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(f, sf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setField parses s into f. Lists are comma separated.
+func setField(f reflect.Value, s string) error {
+	switch {
+	case f.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("must be a duration such as 30s")
+		}
+		f.SetInt(int64(d))
+	case f.Kind() == reflect.String:
+		f.SetString(s)
+	case f.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("must be true or false")
+		}
+		f.SetBool(b)
+	case f.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		f.SetInt(int64(n))
+	case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String:
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		f.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported setting type %s", f.Type())
+	}
+	return nil
+}
+
+// validate checks every setting and reports all problems at once, named by
+// their environment variables.
+func (c *Config) validate() error {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(sf reflect.StructField) string {
+		if key := sf.Tag.Get("env"); key != "" {
+			return key
+		}
+		return sf.Tag.Get("yaml")
+	})
+
+	err := v.Struct(c)
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err
+	}
+	var msg strings.Builder
+	msg.WriteString("invalid configuration:")
+	for _, fe := range verrs {
+		fmt.Fprintf(&msg, "\n  %s %s", fe.Field(), ruleText(fe))
+	}
+	return errors.New(msg.String())
+}
+
+func ruleText(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "numeric":
+		return "must be a number"
+	case "url":
+		return "must be a URL"
+	case "cidr|ip":
+		return "must be an IP address or CIDR range"
+	case "min", "gte":
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		return "must be at most " + fe.Param()
+	}
+	return fmt.Sprintf("fails %s=%s", fe.Tag(), fe.Param())
+}
+
+// Redacted returns a copy of c with the secrets replaced, for printing.
+func (c Config) Redacted() Config {
+	walk(reflect.ValueOf(&c).Elem(), func(f reflect.Value, sf reflect.StructField) error {
+		if sf.Tag.Get("secret") == "true" && f.String() != "" {
+			f.SetString(logging.Redacted)
+		}
+		return nil
+	})
+	return c
+}
+
+// String renders the configuration as YAML with the secrets redacted.
+func (c Config) String() string {
+	out, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
+
+// LogValue logs the configuration with the secrets redacted.
+func (c Config) LogValue() slog.Value {
+	var m map[string]any
+	if err := yaml.Unmarshal([]byte(c.String()), &m); err != nil {
+		return slog.StringValue(err.Error())
+	}
+	return slog.AnyValue(m)
+}
@@ -0,0 +1,26 @@
+package config
+
+import "github.com/leo140803/finance-app-backend/mailer"
+
+// Mailer delivers password reset and verification emails.
+var Mailer mailer.Mailer
+
+// AppURL is the frontend base URL used in links sent by email.
+var AppURL string
+
+// InitMailer builds the Mailer selected by cfg.Driver.
+func InitMailer(cfg MailConfig) {
+	switch cfg.Driver {
+	case "smtp":
+		Mailer = &mailer.SMTPMailer{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.From,
+		}
+	default:
+		Mailer = mailer.NewFileMailer(cfg.Dir, cfg.From)
+	}
+	AppURL = cfg.AppURL
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"time"
+
+	"github.com/leo140803/finance-app-backend/ratelimit"
+)
+
+// RateLimitStore holds the counters used to throttle auth endpoints.
+var RateLimitStore ratelimit.Store
+
+// LoginLockout locks an email after repeated failed logins: 5 failures in
+// 15 minutes lock it for 1 minute, doubling with every further lockout in
+// the same day up to an hour.
+var LoginLockout *ratelimit.Lockout
+
+// InitRateLimit selects the counter store: "memory" (single instance) or
+// "supabase" (shared between instances).
+func InitRateLimit(cfg RateLimitConfig) {
+	switch cfg.Store {
+	case "supabase":
+		RateLimitStore = &ratelimit.SupabaseStore{DB: DB}
+	default:
+		RateLimitStore = ratelimit.NewMemoryStore()
+	}
+
+	LoginLockout = &ratelimit.Lockout{
+		Store:        RateLimitStore,
+		MaxFailures:  5,
+		Window:       15 * time.Minute,
+		BaseDuration: time.Minute,
+		MaxDuration:  time.Hour,
+		Memory:       24 * time.Hour,
+	}
+}
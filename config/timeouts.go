@@ -0,0 +1,27 @@
+package config
+
+import "time"
+
+// TimeoutConfig bounds how long requests and the storage calls they make
+// may take. Zero disables a timeout.
+type TimeoutConfig struct {
+	// Request is the deadline of a whole API request.
+	Request time.Duration `yaml:"request" env:"REQUEST_TIMEOUT" default:"30s" validate:"gte=0"`
+	// BulkRequest replaces Request for exports and imports, which stream
+	// or insert many rows.
+	BulkRequest time.Duration `yaml:"bulk_request" env:"BULK_REQUEST_TIMEOUT" default:"5m" validate:"gte=0"`
+	// Storage bounds every single Supabase database call.
+	Storage time.Duration `yaml:"storage" env:"STORAGE_TIMEOUT" default:"10s" validate:"gte=0"`
+	// Shutdown bounds how long a stopping server waits for in-flight
+	// requests and background work.
+	Shutdown time.Duration `yaml:"shutdown" env:"SHUTDOWN_TIMEOUT" default:"25s" validate:"gte=0"`
+}
+
+var Timeouts TimeoutConfig
+
+// InitTimeouts applies the timeouts, including the storage timeout of DB,
+// so it must run after InitDB.
+func InitTimeouts(cfg TimeoutConfig) {
+	Timeouts = cfg
+	DB.Timeout = Timeouts.Storage
+}
@@ -0,0 +1,215 @@
+// Package db wraps the Supabase PostgREST client so that every request
+// goes through a single Execute, where it is measured and traced.
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lengzuo/supa/postgres"
+	"github.com/lengzuo/supa/utils/enum"
+	"github.com/leo140803/finance-app-backend/metrics"
+	"github.com/leo140803/finance-app-backend/tracing"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Client mirrors the builder API of postgres.API.
+type Client struct {
+	api postgres.API
+	// Timeout bounds every request made through the client; zero means
+	// only the caller's context applies.
+	Timeout time.Duration
+}
+
+func New(api postgres.API) *Client {
+	return &Client{api: api}
+}
+
+func (c *Client) From(table string) *RequestBuilder {
+	return &RequestBuilder{b: c.api.From(table), table: table, timeout: c.Timeout}
+}
+
+// RPC calls a Postgres function.
+func (c *Client) RPC(fn string, params interface{}) *Query {
+	return &Query{table: fn, operation: "rpc", timeout: c.Timeout, exec: c.api.RPC(fn, body{params})}
+}
+
+type executor interface {
+	Execute(ctx context.Context, result interface{}) error
+}
+
+// Query is a request that is ready to run.
+type Query struct {
+	table     string
+	operation string
+	timeout   time.Duration
+	exec      executor
+}
+
+// Execute sends the request and unmarshals the response into result, like
+// the postgres builders do. The request gets its own span under the one in
+// ctx. A request cut short by a deadline returns an error matching
+// ErrTimeout.
+func (q *Query) Execute(ctx context.Context, result interface{}) error {
+	if q.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, q.timeout)
+		defer cancel()
+	}
+	ctx, span := tracing.Tracer().Start(ctx, q.operation+" "+q.table,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBCollectionName(q.table),
+			semconv.DBOperationName(q.operation),
+		))
+	defer span.End()
+
+	start := time.Now()
+	err := q.exec.Execute(ctx, result)
+
+	metrics.StorageDuration.WithLabelValues(q.table, q.operation).Observe(time.Since(start).Seconds())
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w: %s %s: %w", ErrTimeout, q.operation, q.table, err)
+		markTimedOut(ctx)
+	}
+	if err != nil {
+		metrics.StorageErrors.WithLabelValues(q.table, q.operation).Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+type RequestBuilder struct {
+	b       *postgres.RequestBuilder
+	table   string
+	timeout time.Duration
+}
+
+func (b *RequestBuilder) query(operation string, exec executor) *Query {
+	return &Query{table: b.table, operation: operation, timeout: b.timeout, exec: exec}
+}
+
+func (b *RequestBuilder) Select(columns ...string) *SelectBuilder {
+	s := b.b.Select(columns...)
+	return &SelectBuilder{
+		FilterBuilder: FilterBuilder{Query: *b.query("select", s), f: &s.FilterRequestBuilder},
+		s:             s,
+	}
+}
+
+// body hands a request body to the postgres client. Their HTTP client
+// reads url tags off the body before encoding it and fails on anything but
+// a struct, so maps and slices are wrapped in one that has no such tags.
+type body struct {
+	v interface{}
+}
+
+func (b body) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.v)
+}
+
+func (b *RequestBuilder) Insert(v interface{}) *Query {
+	return b.query("insert", b.b.Insert(body{v}))
+}
+
+func (b *RequestBuilder) Upsert(v interface{}) *Query {
+	return b.query("upsert", b.b.Upsert(body{v}))
+}
+
+func (b *RequestBuilder) Update(v interface{}) *FilterBuilder {
+	f := b.b.Update(body{v})
+	return &FilterBuilder{Query: *b.query("update", f), f: f}
+}
+
+// Delete removes the filtered rows. Execute unmarshals the deleted rows
+// into a non-nil result, so callers can tell whether anything matched.
+func (b *RequestBuilder) Delete() *FilterBuilder {
+	// The postgres builder only asks PostgREST to return rows on insert,
+	// upsert and update, and Delete has no header option. Update sets
+	// Prefer: return=representation on the headers this request shares;
+	// Execute drops it again when result is nil.
+	b.b.Update(nil)
+	f := b.b.Delete()
+	return &FilterBuilder{Query: *b.query("delete", f), f: f}
+}
+
+// FilterBuilder adds filters to a select, update or delete request.
+type FilterBuilder struct {
+	Query
+	f *postgres.FilterRequestBuilder
+}
+
+// Not negates the next filter.
+func (b *FilterBuilder) Not() *FilterBuilder {
+	b.f.Not()
+	return b
+}
+
+func (b *FilterBuilder) Eq(column, value string) *FilterBuilder {
+	b.f.Eq(column, value)
+	return b
+}
+
+func (b *FilterBuilder) Neq(column, value string) *FilterBuilder {
+	b.f.Neq(column, value)
+	return b
+}
+
+func (b *FilterBuilder) Gt(column, value string) *FilterBuilder {
+	b.f.Gt(column, value)
+	return b
+}
+
+func (b *FilterBuilder) Gte(column, value string) *FilterBuilder {
+	b.f.Gte(column, value)
+	return b
+}
+
+func (b *FilterBuilder) Lt(column, value string) *FilterBuilder {
+	b.f.Lt(column, value)
+	return b
+}
+
+func (b *FilterBuilder) Lte(column, value string) *FilterBuilder {
+	b.f.Lte(column, value)
+	return b
+}
+
+func (b *FilterBuilder) Is(column, value string) *FilterBuilder {
+	b.f.Is(column, value)
+	return b
+}
+
+func (b *FilterBuilder) In(column string, values []string) *FilterBuilder {
+	b.f.In(column, values)
+	return b
+}
+
+// SelectBuilder adds ordering and paging to a select request.
+type SelectBuilder struct {
+	FilterBuilder
+	s *postgres.SelectRequestBuilder
+}
+
+func (b *SelectBuilder) Order(column string, order enum.Order) *SelectBuilder {
+	b.s.Order(column, order)
+	return b
+}
+
+// Range limits the result to rows from through to, inclusive.
+func (b *SelectBuilder) Range(from, to int) *SelectBuilder {
+	b.s.Range(from, to)
+	return b
+}
+
+func (b *SelectBuilder) Limit(count int) *SelectBuilder {
+	b.s.Limit(count)
+	return b
+}
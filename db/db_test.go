@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lengzuo/supa/pkg/logger"
+	"github.com/lengzuo/supa/postgres"
+)
+
+type row struct {
+	Name string `json:"name"`
+}
+
+func TestRequestBodies(t *testing.T) {
+	logger.New(false) // supabase.New would set up the client's logger
+
+	tests := []struct {
+		name string
+		run  func(c *Client) error
+		want string
+	}{
+		{"insert struct", func(c *Client) error {
+			return c.From("t").Insert(row{Name: "a"}).Execute(context.Background(), nil)
+		}, `{"name":"a"}`},
+		{"insert slice", func(c *Client) error {
+			return c.From("t").Upsert([]row{{Name: "a"}, {Name: "b"}}).Execute(context.Background(), nil)
+		}, `[{"name":"a"},{"name":"b"}]`},
+		{"update map", func(c *Client) error {
+			return c.From("t").Update(map[string]string{"name": "a"}).Eq("id", "1").Execute(context.Background(), nil)
+		}, `{"name":"a"}`},
+		{"rpc map", func(c *Client) error {
+			return c.RPC("f", map[string]int{"p": 1}).Execute(context.Background(), nil)
+		}, `{"p":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				got = string(b)
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer srv.Close()
+
+			if err := tt.run(New(postgres.New(srv.URL + "/rest/v1"))); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteReturnsRows(t *testing.T) {
+	logger.New(false)
+
+	tests := []struct {
+		name   string
+		result interface{}
+		prefer string
+	}{
+		{"with result", &[]row{}, "return=representation"},
+		{"without result", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prefer string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				prefer = r.Header.Get("Prefer")
+				w.Write([]byte(`[]`))
+			}))
+			defer srv.Close()
+
+			c := New(postgres.New(srv.URL + "/rest/v1"))
+			if err := c.From("t").Delete().Eq("id", "1").Execute(context.Background(), tt.result); err != nil {
+				t.Fatal(err)
+			}
+			if prefer != tt.prefer {
+				t.Errorf("Prefer = %q, want %q", prefer, tt.prefer)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrTimeout is returned when a request does not finish before its
+// per-call timeout or the caller's deadline.
+var ErrTimeout = errors.New("storage request timed out")
+
+type timeoutKey struct{}
+
+// TrackTimeouts returns a context that remembers whether any request made
+// with it (or a context derived from it) timed out, and a function that
+// reports it. Handlers often turn storage errors into their own responses,
+// so this lets the HTTP layer tell a timeout apart afterwards.
+func TrackTimeouts(ctx context.Context) (context.Context, func() bool) {
+	flag := new(atomic.Bool)
+	return context.WithValue(ctx, timeoutKey{}, flag), flag.Load
+}
+
+func markTimedOut(ctx context.Context) {
+	if flag, ok := ctx.Value(timeoutKey{}).(*atomic.Bool); ok {
+		flag.Store(true)
+	}
+}
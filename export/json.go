@@ -0,0 +1,57 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// jsonWriter writes a JSON array of objects keyed by column name, keeping
+// the column order of the table.
+type jsonWriter struct {
+	w       *bufio.Writer
+	columns []string
+	rows    int
+}
+
+func newJSONWriter(w io.Writer, columns []string) (*jsonWriter, error) {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString("["); err != nil {
+		return nil, err
+	}
+	return &jsonWriter{w: bw, columns: columns}, nil
+}
+
+func (j *jsonWriter) WriteRow(values ...any) error {
+	if len(values) != len(j.columns) {
+		return fmt.Errorf("row has %d values, expected %d", len(values), len(j.columns))
+	}
+	if j.rows > 0 {
+		j.w.WriteString(",")
+	}
+	j.w.WriteString("{")
+	for i, col := range j.columns {
+		if i > 0 {
+			j.w.WriteString(",")
+		}
+		key, _ := json.Marshal(col)
+		val, err := json.Marshal(values[i])
+		if err != nil {
+			return err
+		}
+		j.w.Write(key)
+		j.w.WriteString(":")
+		j.w.Write(val)
+	}
+	j.rows++
+	_, err := j.w.WriteString("}")
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	if _, err := j.w.WriteString("]"); err != nil {
+		return err
+	}
+	return j.w.Flush()
+}
@@ -0,0 +1,62 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is an output format supported by the export endpoints.
+type Format string
+
+const (
+	CSV  Format = "csv"
+	JSON Format = "json"
+	XLSX Format = "xlsx"
+)
+
+// ParseFormat validates a ?format= query value, defaulting to CSV.
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(strings.TrimSpace(s))) {
+	case "", CSV:
+		return CSV, nil
+	case JSON:
+		return JSON, nil
+	case XLSX:
+		return XLSX, nil
+	}
+	return "", fmt.Errorf("unsupported export format %q (use csv, json or xlsx)", s)
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case JSON:
+		return "application/json"
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Writer writes a table one row at a time so callers can stream large
+// result sets without holding them in memory.
+type Writer interface {
+	// WriteRow writes one row; values must line up with the columns
+	// the writer was created with.
+	WriteRow(values ...any) error
+	// Close flushes any buffered output and writes trailing markup.
+	Close() error
+}
+
+// NewWriter returns a Writer for the given format writing to w.
+func NewWriter(f Format, w io.Writer, columns []string) (Writer, error) {
+	switch f {
+	case CSV:
+		return newCSVWriter(w, columns)
+	case JSON:
+		return newJSONWriter(w, columns)
+	case XLSX:
+		return newXLSXWriter(w, columns)
+	}
+	return nil, fmt.Errorf("unsupported export format %q", f)
+}
@@ -0,0 +1,86 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFormatCell(t *testing.T) {
+	tests := []struct {
+		in   any
+		want string
+	}{
+		{"Groceries", "Groceries"},
+		{"", ""},
+		{"=1+1", "'=1+1"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tx", "'\tx"},
+		{"\rx", "'\rx"},
+		{"a=b", "a=b"},
+		{-12.5, "-12.5"},
+		{3, "3"},
+		{true, "true"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := formatCell(tt.in); got != tt.want {
+			t.Errorf("formatCell(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormulasAreEscaped(t *testing.T) {
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{CSV, `"'=HYPERLINK(""x"")",-4` + "\n"},
+		{XLSX, `<t xml:space="preserve">&#39;=HYPERLINK(&#34;x&#34;)</t></is></c><c><v>-4</v></c>`},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(tt.format, &buf, []string{"description", "amount"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.WriteRow(`=HYPERLINK("x")`, -4.0); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			got := buf.String()
+			if tt.format == XLSX {
+				got = readSheet(t, buf.Bytes())
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("output %s does not contain %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func readSheet(t *testing.T, b []byte) string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := zr.Open("xl/worksheets/sheet1.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sheet, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(sheet)
+}
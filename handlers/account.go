@@ -1,55 +1,83 @@
 package handlers
 
 import (
-	"context"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
 	"github.com/leo140803/finance-app-backend/config"
 	"github.com/leo140803/finance-app-backend/models"
 )
 
 func GetAccounts(c *gin.Context) {
-	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	// Personal accounts, or the household's (set by middleware)
+	ws, ok := currentWorkspace(c, models.RoleViewer)
+	if !ok {
 		return
 	}
 
 	var accounts []models.Account
 	
-	err := config.SupaClient.DB.From("accounts").Select("*").Eq("user_id", userID.(string)).Execute(context.Background(), &accounts)
+	q := config.DB.From("accounts").Select("*")
+	err := ws.scope(&q.FilterBuilder).Execute(c.Request.Context(), &accounts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch accounts"})
+		apierror.Respond(c, fmt.Errorf("fetch accounts: %w", err))
 		return
 	}
 
+	// Accounts other users shared with this one are listed with their access
+	if ws.householdID == "" {
+		shared, err := sharedAccountIDs(c.Request.Context(), ws.userID)
+		if err != nil {
+			apierror.Respond(c, fmt.Errorf("fetch accounts: %w", err))
+			return
+		}
+		if len(shared) > 0 {
+			ids := make([]string, 0, len(shared))
+			for id := range shared {
+				ids = append(ids, id)
+			}
+			var sharedAccounts []models.Account
+			err = config.DB.From("accounts").Select("*").In("id", ids).Execute(c.Request.Context(), &sharedAccounts)
+			if err != nil {
+				apierror.Respond(c, fmt.Errorf("fetch accounts: %w", err))
+				return
+			}
+			for _, acc := range sharedAccounts {
+				acc.Access = shared[acc.ID]
+				accounts = append(accounts, acc)
+			}
+		}
+	}
+
 	c.JSON(http.StatusOK, accounts)
 }
 
 func CreateAccount(c *gin.Context) {
-	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	ws, ok := currentWorkspace(c, models.RoleEditor)
+	if !ok {
 		return
 	}
 
 	var acc models.Account
 	if err := c.ShouldBindJSON(&acc); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
 	// Set user ID from authentication context
-	acc.UserID = userID.(string)
+	acc.Name = strings.TrimSpace(acc.Name)
+	acc.UserID = ws.userID
+	acc.HouseholdID = ws.householdID
+	acc.ID, acc.CreatedAt, acc.Access = "", "", ""
 
 	// Use interface{} to handle flexible response format from Supabase
 	var result interface{}
-	err := config.SupaClient.DB.From("accounts").Insert(acc).Execute(context.Background(), &result)
+	err := config.DB.From("accounts").Insert(acc).Execute(c.Request.Context(), &result)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/models"
+)
+
+// DeleteAccount schedules the erasure of the user and all their data after
+// the grace period. The user must confirm their password, and their 2FA
+// code when enabled.
+func DeleteAccount(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+	if config.AuthAdmin == nil {
+		apierror.Respond(c, apierror.ErrDisabled)
+		return
+	}
+
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	ctx := c.Request.Context()
+
+	var users []models.User
+	err := config.DB.From("users").Select("*").Eq("id", userID.(string)).Execute(ctx, &users)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch user: %w", err))
+		return
+	}
+	if len(users) == 0 {
+		apierror.Respond(c, apierror.ErrUserNotFound)
+		return
+	}
+
+	session := checkPassword(c, users[0].Email, req.Password)
+	if session == nil {
+		return
+	}
+	// The session opened to check the password is not needed
+	if err := config.SignOut(ctx, session.AccessToken, "local"); err != nil {
+		apierror.Respond(c, fmt.Errorf("sign out password check: %w", err))
+		return
+	}
+
+	tf, err := getTwoFactor(ctx, userID.(string))
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch 2FA settings: %w", err))
+		return
+	}
+	if tf != nil && tf.Enabled {
+		if checkTwoFactorCode(c, userID.(string), req.Code) == nil {
+			return
+		}
+	}
+
+	// Shared households must not be left without an owner
+	var memberships []models.HouseholdMember
+	err = config.DB.From("household_members").Select("*").
+		Eq("user_id", userID.(string)).
+		Eq("role", models.RoleOwner).
+		Execute(ctx, &memberships)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch households: %w", err))
+		return
+	}
+	for _, m := range memberships {
+		members, err := householdMembers(ctx, m.HouseholdID)
+		if err != nil {
+			apierror.Respond(c, fmt.Errorf("fetch members: %w", err))
+			return
+		}
+		if len(members) > 1 && isLastOwner(members, m.UserID) {
+			// Make another member an owner or delete the household first
+			apierror.Respond(c, apierror.ErrLastOwner.With("household_id", m.HouseholdID))
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	deletion := models.AccountDeletion{
+		UserID:       userID.(string),
+		AuthUserID:   c.GetString("auth_user_id"),
+		Email:        users[0].Email,
+		RequestedAt:  now.Format(time.RFC3339),
+		ScheduledFor: now.Add(config.Auth.DeletionGracePeriod).Format(time.RFC3339),
+	}
+
+	var result interface{}
+	err = config.DB.From("account_deletions").Upsert(deletion).Execute(ctx, &result)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("schedule account deletion: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":       "Account scheduled for deletion",
+		"scheduled_for": deletion.ScheduledFor,
+	})
+}
+
+func GetAccountDeletion(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	var deletions []models.AccountDeletion
+	err := config.DB.From("account_deletions").Select("user_id,requested_at,scheduled_for").
+		Eq("user_id", userID.(string)).
+		Execute(c.Request.Context(), &deletions)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch account deletion: %w", err))
+		return
+	}
+
+	if len(deletions) == 0 {
+		apierror.Respond(c, apierror.ErrDeletionNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, deletions[0])
+}
+
+// CancelAccountDeletion keeps the account during the grace period.
+func CancelAccountDeletion(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	var deleted []models.AccountDeletion
+	err := config.DB.From("account_deletions").
+		Delete().
+		Eq("user_id", userID.(string)).
+		Execute(c.Request.Context(), &deleted)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("cancel account deletion: %w", err))
+		return
+	}
+
+	if len(deleted) == 0 {
+		apierror.Respond(c, apierror.ErrDeletionNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled"})
+}
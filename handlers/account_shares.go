@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/db"
+	"github.com/leo140803/finance-app-backend/models"
+)
+
+// sharedAccountIDs returns the IDs of the accounts shared with the user,
+// mapped to the access they were given.
+func sharedAccountIDs(ctx context.Context, userID string) (map[string]string, error) {
+	var shares []models.AccountShare
+	err := config.DB.From("account_shares").Select("account_id,access").Eq("user_id", userID).Execute(ctx, &shares)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]string, len(shares))
+	for _, s := range shares {
+		ids[s.AccountID] = s.Access
+	}
+	return ids, nil
+}
+
+// transactionScope returns the filter selecting the transactions the
+// workspace can see: ws.scope, plus, in the personal workspace, those on
+// accounts shared with the user, which belong to the account owner.
+func transactionScope(ctx context.Context, ws workspace) (func(*db.FilterBuilder) *db.FilterBuilder, error) {
+	if ws.householdID != "" {
+		return ws.scope, nil
+	}
+	shared, err := sharedAccountIDs(ctx, ws.userID)
+	if err != nil || len(shared) == 0 {
+		return ws.scope, err
+	}
+
+	// A personal transaction is always on one of the user's personal
+	// accounts, so both sets can be matched by account.
+	var owned []models.Account
+	q := config.DB.From("accounts").Select("id")
+	if err := ws.scope(&q.FilterBuilder).Execute(ctx, &owned); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(owned)+len(shared))
+	for _, acc := range owned {
+		ids = append(ids, acc.ID)
+	}
+	for id := range shared {
+		ids = append(ids, id)
+	}
+	return func(q *db.FilterBuilder) *db.FilterBuilder {
+		return q.In("account_id", ids)
+	}, nil
+}
+
+// accessibleAccount loads an account owned by the workspace, or, in the
+// personal workspace, one shared with the user. It returns the access the
+// user has, which is always "write" for owned accounts, or nil if the
+// account cannot be seen.
+func accessibleAccount(ctx context.Context, ws workspace, accountID string) (*models.Account, string, error) {
+	var accounts []models.Account
+	q := config.DB.From("accounts").Select("*")
+	q.Eq("id", accountID)
+	if err := ws.scope(&q.FilterBuilder).Execute(ctx, &accounts); err != nil {
+		return nil, "", err
+	}
+	if len(accounts) > 0 {
+		return &accounts[0], models.AccessWrite, nil
+	}
+	if ws.householdID != "" {
+		return nil, "", nil
+	}
+
+	var shares []models.AccountShare
+	err := config.DB.From("account_shares").Select("access").
+		Eq("account_id", accountID).
+		Eq("user_id", ws.userID).
+		Execute(ctx, &shares)
+	if err != nil || len(shares) == 0 {
+		return nil, "", err
+	}
+
+	err = config.DB.From("accounts").Select("*").Eq("id", accountID).Execute(ctx, &accounts)
+	if err != nil || len(accounts) == 0 {
+		return nil, "", err
+	}
+	accounts[0].Access = shares[0].Access
+	return &accounts[0], shares[0].Access, nil
+}
+
+// accessibleTransaction is accessibleAccount for a single transaction.
+func accessibleTransaction(ctx context.Context, ws workspace, txID string) (*models.Transaction, string, error) {
+	var txs []models.Transaction
+	q := config.DB.From("transactions").Select("*")
+	q.Eq("id", txID)
+	if err := ws.scope(&q.FilterBuilder).Execute(ctx, &txs); err != nil {
+		return nil, "", err
+	}
+	if len(txs) > 0 {
+		return &txs[0], models.AccessWrite, nil
+	}
+	if ws.householdID != "" {
+		return nil, "", nil
+	}
+
+	err := config.DB.From("transactions").Select("*").Eq("id", txID).Execute(ctx, &txs)
+	if err != nil || len(txs) == 0 {
+		return nil, "", err
+	}
+	acc, access, err := accessibleAccount(ctx, ws, txs[0].AccountID)
+	if err != nil || acc == nil {
+		return nil, "", err
+	}
+	return &txs[0], access, nil
+}
+
+// ownedAccount checks that the :id route parameter names one of the user's
+// personal accounts. Household accounts are shared through the household
+// instead. Otherwise it writes the error response and returns false.
+func ownedAccount(c *gin.Context) (string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return "", false
+	}
+
+	var accounts []models.Account
+	err := config.DB.From("accounts").Select("id").
+		Eq("id", c.Param("id")).
+		Eq("user_id", userID.(string)).
+		Is("household_id", "null").
+		Execute(c.Request.Context(), &accounts)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch account: %w", err))
+		return "", false
+	}
+	if len(accounts) == 0 {
+		apierror.Respond(c, apierror.ErrAccountNotFound)
+		return "", false
+	}
+	return accounts[0].ID, true
+}
+
+func GetAccountShares(c *gin.Context) {
+	accountID, ok := ownedAccount(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	shares := []models.AccountShare{}
+	err := config.DB.From("account_shares").Select("*").Eq("account_id", accountID).Execute(ctx, &shares)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch shares: %w", err))
+		return
+	}
+	if len(shares) == 0 {
+		c.JSON(http.StatusOK, shares)
+		return
+	}
+
+	ids := make([]string, len(shares))
+	for i, s := range shares {
+		ids[i] = s.UserID
+	}
+	var users []models.User
+	err = config.DB.From("users").Select("id,email").In("id", ids).Execute(ctx, &users)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch shares: %w", err))
+		return
+	}
+	emails := make(map[string]string, len(users))
+	for _, u := range users {
+		emails[u.ID] = u.Email
+	}
+	for i := range shares {
+		shares[i].Email = emails[shares[i].UserID]
+	}
+
+	c.JSON(http.StatusOK, shares)
+}
+
+// ShareAccount grants a registered user access to the account, or changes
+// the access they already have.
+func ShareAccount(c *gin.Context) {
+	accountID, ok := ownedAccount(c)
+	if !ok {
+		return
+	}
+
+	var req models.ShareAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	ctx := c.Request.Context()
+
+	var users []models.User
+	err := config.DB.From("users").Select("id,email").Eq("email", req.Email).Execute(ctx, &users)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch user: %w", err))
+		return
+	}
+	if len(users) == 0 {
+		apierror.Respond(c, apierror.ErrUserNotFound)
+		return
+	}
+	if users[0].ID == c.GetString("user_id") {
+		apierror.Respond(c, apierror.ErrShareWithSelf)
+		return
+	}
+
+	share := models.AccountShare{AccountID: accountID, UserID: users[0].ID, Access: req.Access}
+	var result interface{}
+	err = config.DB.From("account_shares").Upsert(share).Execute(ctx, &result)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("share account: %w", err))
+		return
+	}
+
+	share.Email = users[0].Email
+	c.JSON(http.StatusOK, share)
+}
+
+// UnshareAccount revokes a user's access. The account owner can revoke
+// anyone's; a grantee can remove an account shared with them.
+func UnshareAccount(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	granteeID := c.Param("user_id")
+	accountID := c.Param("id")
+	if granteeID != userID.(string) {
+		var ok bool
+		if accountID, ok = ownedAccount(c); !ok {
+			return
+		}
+	}
+
+	var deleted []models.AccountShare
+	err := config.DB.From("account_shares").
+		Delete().
+		Eq("account_id", accountID).
+		Eq("user_id", granteeID).
+		Execute(c.Request.Context(), &deleted)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("remove share: %w", err))
+		return
+	}
+
+	if len(deleted) == 0 {
+		apierror.Respond(c, apierror.ErrShareNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account is no longer shared with this user"})
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lengzuo/supa/pkg/logger"
+	"github.com/lengzuo/supa/postgres"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/db"
+)
+
+// fakePostgREST answers updates and deletes like PostgREST: with the
+// affected rows when the request asks for them, otherwise with 204 No
+// Content.
+func fakePostgREST(t *testing.T, rows string) {
+	t.Helper()
+	logger.New(false) // supabase.New would set up the client's logger
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPatch && r.Method != http.MethodDelete {
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !strings.Contains(r.Header.Get("Prefer"), "return=representation") {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(rows))
+	}))
+	t.Cleanup(srv.Close)
+
+	prev := config.DB
+	config.DB = db.New(postgres.New(srv.URL + "/rest/v1"))
+	t.Cleanup(func() { config.DB = prev })
+}
+
+func TestUnshareAccount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		rows   string
+		status int
+	}{
+		{"removed", `[{"account_id":"acc-1","user_id":"user-1","access":"read"}]`, http.StatusOK},
+		{"not shared", `[]`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakePostgREST(t, tt.rows)
+
+			// A grantee removing an account shared with them
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodDelete, "/api/accounts/acc-1/shares/user-1", nil)
+			c.Params = gin.Params{{Key: "id", Value: "acc-1"}, {Key: "user_id", Value: "user-1"}}
+			c.Set("user_id", "user-1")
+
+			UnshareAccount(c)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/middleware"
+	"github.com/leo140803/finance-app-backend/models"
+	"github.com/lengzuo/supa/utils/enum"
+)
+
+// apiTokenColumns leaves out token_hash so it never reaches a response.
+const apiTokenColumns = "id,name,prefix,scopes,expires_at,last_used_at,created_at"
+
+const defaultAPITokenDays = 90
+
+func GetAPITokens(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	var tokens []models.APIToken
+	err := config.DB.From("api_tokens").Select(apiTokenColumns).
+		Order("created_at", enum.OrderDesc).
+		Eq("user_id", userID.(string)).
+		Is("revoked_at", "null").
+		Execute(c.Request.Context(), &tokens)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch API tokens: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func CreateAPIToken(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	var req models.CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	if req.ExpiresInDays == 0 {
+		req.ExpiresInDays = defaultAPITokenDays
+	}
+
+	token, err := middleware.NewAPIToken()
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("generate token: %w", err))
+		return
+	}
+
+	apiToken := models.APIToken{
+		UserID:    userID.(string),
+		Name:      req.Name,
+		TokenHash: middleware.HashToken(token),
+		// Enough of the token to recognise it in a list
+		Prefix:    token[:len(middleware.APITokenPrefix)+6],
+		Scopes:    req.Scopes,
+		ExpiresAt: time.Now().UTC().AddDate(0, 0, req.ExpiresInDays).Format(time.RFC3339),
+	}
+
+	var created models.APIToken
+	err = config.DB.From("api_tokens").Insert(apiToken).Execute(c.Request.Context(), &created)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("create API token: %w", err))
+		return
+	}
+	created.TokenHash = ""
+	created.UserID = ""
+
+	c.JSON(http.StatusCreated, models.CreateAPITokenResponse{APIToken: created, Token: token})
+}
+
+func RevokeAPIToken(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	tokenID := c.Param("id")
+
+	var revoked []models.APIToken
+	err := config.DB.From("api_tokens").
+		Update(map[string]string{"revoked_at": time.Now().UTC().Format(time.RFC3339)}).
+		Eq("id", tokenID).
+		Eq("user_id", userID.(string)).
+		Is("revoked_at", "null").
+		Execute(c.Request.Context(), &revoked)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("revoke API token: %w", err))
+		return
+	}
+
+	if len(revoked) == 0 {
+		apierror.Respond(c, apierror.ErrAPITokenNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API token revoked successfully"})
+}
@@ -2,18 +2,29 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
 	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/metrics"
+	"github.com/leo140803/finance-app-backend/middleware"
 	"github.com/leo140803/finance-app-backend/models"
 	"github.com/lengzuo/supa/dto"
+	"github.com/lengzuo/supa/postgres"
 )
 
 func Register(c *gin.Context) {
 	var req models.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	if !middleware.LimitEmail(c, middleware.RegisterLimit, req.Email, 5, time.Hour) {
 		return
 	}
 
@@ -23,9 +34,9 @@ func Register(c *gin.Context) {
 		Password: req.Password,
 	}
 	
-	authResponse, err := config.SupaClient.Auth.SignUp(context.Background(), signUpReq)
+	authResponse, err := config.SupaClient.Auth.SignUp(c.Request.Context(), signUpReq)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user: " + err.Error()})
+		apierror.Respond(c, signUpError(err))
 		return
 	}
 
@@ -36,26 +47,31 @@ func Register(c *gin.Context) {
 
 	// Try to insert user record, but don't fail if it already exists
 	var result []models.User
-	err = config.SupaClient.DB.From("users").Insert(user).Execute(context.Background(), &result)
+	err = config.DB.From("users").Insert(user).Execute(c.Request.Context(), &result)
 	
 	// If insert fails, try to get existing user
 	if err != nil {
 		// Try to get existing user by email
 		var existingUsers []models.User
-		err = config.SupaClient.DB.From("users").Select("*").Eq("email", req.Email).Execute(context.Background(), &existingUsers)
+		err = config.DB.From("users").Select("*").Eq("email", req.Email).Execute(c.Request.Context(), &existingUsers)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create or fetch user record: " + err.Error()})
+			apierror.Respond(c, fmt.Errorf("create or fetch user record: %w", err))
 			return
 		}
 		
 		if len(existingUsers) > 0 {
 			result = existingUsers
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user record and user not found"})
+			apierror.Respond(c, errors.New("create user record: user not found"))
 			return
 		}
 	}
 
+	if err := middleware.StartSession(c, result[0].ID, authResponse.AccessToken, false); err != nil {
+		apierror.Respond(c, fmt.Errorf("record session: %w", err))
+		return
+	}
+
 	response := models.AuthResponse{
 		User:         result[0],
 		AccessToken:  authResponse.AccessToken,
@@ -68,7 +84,14 @@ func Register(c *gin.Context) {
 func Login(c *gin.Context) {
 	var req models.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	// Refuse emails locked out after repeated failures
+	lockKey := middleware.LoginLockKey(req.Email)
+	if retryAfter, err := config.LoginLockout.Locked(c.Request.Context(), lockKey); err == nil && retryAfter > 0 {
+		middleware.TooManyRequests(c, retryAfter, apierror.ErrTooManyLoginAttempts)
 		return
 	}
 
@@ -78,22 +101,49 @@ func Login(c *gin.Context) {
 		Password: req.Password,
 	}
 	
-	authResponse, err := config.SupaClient.Auth.SignInWithPassword(context.Background(), signInReq)
+	authResponse, err := config.SupaClient.Auth.SignInWithPassword(c.Request.Context(), signInReq)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials: " + err.Error()})
+		metrics.AuthFailures.WithLabelValues("invalid_credentials").Inc()
+		if lockedFor, _ := config.LoginLockout.Fail(c.Request.Context(), lockKey); lockedFor > 0 {
+			middleware.TooManyRequests(c, lockedFor, apierror.ErrTooManyLoginAttempts)
+			return
+		}
+		apierror.Respond(c, apierror.ErrInvalidCredentials.Wrap(err))
 		return
 	}
+	config.LoginLockout.Succeed(c.Request.Context(), lockKey)
 
 	// Get user from our users table
 	var users []models.User
-	err = config.SupaClient.DB.From("users").Select("*").Eq("email", req.Email).Execute(context.Background(), &users)
+	err = config.DB.From("users").Select("*").Eq("email", req.Email).Execute(c.Request.Context(), &users)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user: " + err.Error()})
+		apierror.Respond(c, fmt.Errorf("fetch user: %w", err))
 		return
 	}
 
 	if len(users) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		apierror.Respond(c, apierror.ErrUserNotFound)
+		return
+	}
+
+	// Users with 2FA get their tokens from LoginTwoFactor instead
+	tf, err := getTwoFactor(c.Request.Context(), users[0].ID)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch 2FA settings: %w", err))
+		return
+	}
+	if tf != nil && tf.Enabled {
+		challenge, err := startLoginChallenge(c.Request.Context(), users[0].ID, authResponse.AccessToken, authResponse.RefreshToken)
+		if err != nil {
+			apierror.Respond(c, fmt.Errorf("start 2FA challenge: %w", err))
+			return
+		}
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	if err := middleware.StartSession(c, users[0].ID, authResponse.AccessToken, false); err != nil {
+		apierror.Respond(c, fmt.Errorf("record session: %w", err))
 		return
 	}
 
@@ -110,19 +160,19 @@ func GetProfile(c *gin.Context) {
 	// Get user ID from context (set by auth middleware)
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		apierror.Respond(c, apierror.ErrUnauthenticated)
 		return
 	}
 
 	var users []models.User
-	err := config.SupaClient.DB.From("users").Select("*").Eq("id", userID.(string)).Execute(context.Background(), &users)
+	err := config.DB.From("users").Select("*").Eq("id", userID.(string)).Execute(c.Request.Context(), &users)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user profile: " + err.Error()})
+		apierror.Respond(c, fmt.Errorf("fetch user profile: %w", err))
 		return
 	}
 
 	if len(users) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		apierror.Respond(c, apierror.ErrUserNotFound)
 		return
 	}
 
@@ -133,7 +183,7 @@ func Logout(c *gin.Context) {
 	// Get refresh token from request
 	refreshToken := c.GetHeader("Authorization")
 	if refreshToken == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Refresh token required"})
+		apierror.Respond(c, apierror.ErrValidation.WithFields(apierror.FieldError{Field: "Authorization", Rule: "required"}))
 		return
 	}
 
@@ -143,11 +193,156 @@ func Logout(c *gin.Context) {
 	}
 
 	// Logout user with Supabase Auth
-	err := config.SupaClient.Auth.SignOut(context.Background(), refreshToken)
+	err := config.SupaClient.Auth.SignOut(c.Request.Context(), refreshToken)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to logout: " + err.Error()})
+		apierror.Respond(c, fmt.Errorf("logout: %w", err))
 		return
 	}
 
+	// Supabase accepted the token, so its session is closed here as well
+	if sessionID := middleware.SessionID(refreshToken); sessionID != "" {
+		revokeSession(c.Request.Context(), sessionID)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
+
+// signUpError maps a failed Supabase sign-up: a weak password is invalid
+// input, any other client error means the email is already registered.
+func signUpError(err error) error {
+	var sc interface{ StatusCode() int }
+	if !errors.As(err, &sc) || sc.StatusCode() >= http.StatusInternalServerError {
+		return fmt.Errorf("register user: %w", err)
+	}
+	if strings.Contains(err.Error(), "weak_password") {
+		return apierror.ErrValidation.Wrap(err).WithFields(apierror.FieldError{Field: "password", Rule: "weak"})
+	}
+	return apierror.ErrEmailTaken.Wrap(err)
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint
+// violation returned through PostgREST.
+func isUniqueViolation(err error) bool {
+	var pgErr *postgres.Error
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// signOutTokenOwner signs out the user a used refresh token was issued to.
+// The user is only recorded once the token has been rotated, so a token
+// replayed while its first exchange is still running is left alone.
+func signOutTokenOwner(ctx context.Context, tokenHash string) error {
+	var used []models.UsedRefreshToken
+	err := config.DB.From("used_refresh_tokens").Select("*").Eq("token_hash", tokenHash).Execute(ctx, &used)
+	if err != nil {
+		return fmt.Errorf("fetch used refresh token: %w", err)
+	}
+	if len(used) == 0 || used[0].UserID == "" {
+		return nil
+	}
+
+	var users []models.User
+	err = config.DB.From("users").Select("*").Eq("id", used[0].UserID).Execute(ctx, &users)
+	if err != nil {
+		return fmt.Errorf("fetch user: %w", err)
+	}
+	if len(users) == 0 {
+		return nil
+	}
+	return signOutEverywhere(ctx, users[0].ID, users[0].Email)
+}
+
+func RefreshToken(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	ctx := c.Request.Context()
+
+	// Claim the refresh token before rotating it. Every token can only be
+	// claimed once, so a second attempt (a replayed or stolen token) fails
+	// on the primary key even when two requests race.
+	used := models.UsedRefreshToken{TokenHash: middleware.HashToken(req.RefreshToken)}
+	err := config.DB.From("used_refresh_tokens").Insert(used).Execute(ctx, nil)
+	if isUniqueViolation(err) {
+		metrics.AuthFailures.WithLabelValues("refresh_token_reuse").Inc()
+		middleware.Log(c).Warn("refresh token reuse detected", "token_hash_prefix", used.TokenHash[:12])
+		// Either the client or someone who copied the token is replaying
+		// it; there is no telling which, so every session of the user
+		// ends, also if the client goes away.
+		if err := signOutTokenOwner(context.WithoutCancel(ctx), used.TokenHash); err != nil {
+			middleware.Log(c).Error("failed to sign out after refresh token reuse", "error", err)
+		}
+		apierror.Respond(c, apierror.ErrRefreshTokenReused)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("record refresh token: %w", err))
+		return
+	}
+
+	authResponse, err := config.SupaClient.Auth.RefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		// The token was never rotated, release the claim so the client
+		// is not locked out by a transient failure.
+		err := config.DB.From("used_refresh_tokens").
+			Delete().
+			Eq("token_hash", used.TokenHash).
+			Execute(context.WithoutCancel(ctx), nil)
+		if err != nil {
+			middleware.Log(c).Error("failed to release refresh token claim", "error", err)
+		}
+		metrics.AuthFailures.WithLabelValues("invalid_refresh_token").Inc()
+		apierror.Respond(c, apierror.ErrInvalidRefreshToken.Wrap(err))
+		return
+	}
+
+	var users []models.User
+	err = config.DB.From("users").Select("*").Eq("email", authResponse.User.Email).Execute(ctx, &users)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch user: %w", err))
+		return
+	}
+
+	if len(users) == 0 {
+		apierror.Respond(c, apierror.ErrUserNotFound)
+		return
+	}
+
+	// The owner is what a later reuse of the token signs out. Supabase has
+	// already rotated the token, so failing here would only lock the
+	// client out.
+	err = config.DB.From("used_refresh_tokens").
+		Update(models.UsedRefreshToken{UserID: users[0].ID}).
+		Eq("token_hash", used.TokenHash).
+		Execute(ctx, nil)
+	if err != nil {
+		middleware.Log(c).Error("failed to record refresh token owner", "user_id", users[0].ID, "error", err)
+	}
+
+	// Revoked sessions must not be kept alive through their refresh token
+	if sessionID := middleware.SessionID(authResponse.AccessToken); sessionID != "" {
+		var sessions []models.Session
+		err = config.DB.From("sessions").Select("*").
+			Eq("id", sessionID).
+			Not().Is("revoked_at", "null").
+			Execute(ctx, &sessions)
+		if err != nil {
+			apierror.Respond(c, fmt.Errorf("check session: %w", err))
+			return
+		}
+		if len(sessions) > 0 {
+			metrics.AuthFailures.WithLabelValues("revoked_session").Inc()
+			apierror.Respond(c, apierror.ErrSessionRevoked)
+			return
+		}
+	}
+
+	response := models.AuthResponse{
+		User:         users[0],
+		AccessToken:  authResponse.AccessToken,
+		RefreshToken: authResponse.RefreshToken,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -1,27 +1,30 @@
 package handlers
 
 import (
-	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
 	"github.com/leo140803/finance-app-backend/config"
 	"github.com/leo140803/finance-app-backend/models"
 )
 
 func GetCategories(c *gin.Context) {
-	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	// Personal categories, or the household's (set by middleware)
+	ws, ok := currentWorkspace(c, models.RoleViewer)
+	if !ok {
 		return
 	}
 
 	var categories []models.Category
 
-	err := config.SupaClient.DB.From("categories").Select("*").Eq("user_id", userID.(string)).Execute(context.Background(), &categories)
+	q := config.DB.From("categories").Select("*")
+	err := ws.scope(&q.FilterBuilder).Execute(c.Request.Context(), &categories)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch categories"})
+		apierror.Respond(c, fmt.Errorf("fetch categories: %w", err))
 		return
 	}
 
@@ -29,21 +32,21 @@ func GetCategories(c *gin.Context) {
 }
 
 func CreateCategory(c *gin.Context) {
-	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	ws, ok := currentWorkspace(c, models.RoleEditor)
+	if !ok {
 		return
 	}
 
 	var cat models.Category
 	if err := c.ShouldBindJSON(&cat); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
 	// Set user ID from authentication context
-	cat.UserID = userID.(string)
+	cat.Name = strings.TrimSpace(cat.Name)
+	cat.UserID = ws.userID
+	cat.HouseholdID = ws.householdID
 	
 	// Clear any existing ID or CreatedAt to let database handle them
 	cat.ID = ""
@@ -51,22 +54,24 @@ func CreateCategory(c *gin.Context) {
 
 	// Use interface{} to handle flexible response format from Supabase
 	var result interface{}
-	err := config.SupaClient.DB.From("categories").Insert(cat).Execute(context.Background(), &result)
+	err := config.DB.From("categories").Insert(cat).Execute(c.Request.Context(), &result)
 	
 	// If insert fails, try to get existing category
 	if err != nil {
-		// Try to get existing category by name and user_id
+		// Try to get existing category by name in the same workspace
 		var existingCategories []models.Category
-		err = config.SupaClient.DB.From("categories").Select("*").Eq("name", cat.Name).Eq("user_id", cat.UserID).Execute(context.Background(), &existingCategories)
+		q := config.DB.From("categories").Select("*")
+		q.Eq("name", cat.Name)
+		err = ws.scope(&q.FilterBuilder).Execute(c.Request.Context(), &existingCategories)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create or fetch category: " + err.Error()})
+			apierror.Respond(c, fmt.Errorf("create or fetch category: %w", err))
 			return
 		}
 		
 		if len(existingCategories) > 0 {
 			c.JSON(http.StatusCreated, existingCategories[0])
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create category and category not found"})
+			apierror.Respond(c, errors.New("create category: category not found"))
 		}
 		return
 	}
@@ -80,40 +85,34 @@ func CreateCategory(c *gin.Context) {
 }
 
 func UpdateCategory(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	ws, ok := currentWorkspace(c, models.RoleEditor)
+	if !ok {
 		return
 	}
 
 	categoryID := c.Param("id")
 
-	var input struct {
-		Name string `json:"name"`
-	}
+	var input models.UpdateCategoryRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
+	// Only the name changes; user_id stays the category's creator
 	var updated []models.Category
-	err := config.SupaClient.DB.
+	q := config.DB.
 		From("categories").
-		Update(models.Category{
-			Name:   input.Name,
-			UserID: userID.(string), // supaya filter tetap aman
-		}).
-		Eq("id", categoryID).
-		Eq("user_id", userID.(string)).
-		Execute(context.Background(), &updated)
+		Update(map[string]string{"name": strings.TrimSpace(input.Name)}).
+		Eq("id", categoryID)
+	err := ws.scope(q).Execute(c.Request.Context(), &updated)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update category: " + err.Error()})
+		apierror.Respond(c, fmt.Errorf("update category: %w", err))
 		return
 	}
 
 	if len(updated) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		apierror.Respond(c, apierror.ErrCategoryNotFound)
 		return
 	}
 
@@ -122,10 +121,8 @@ func UpdateCategory(c *gin.Context) {
 
 
 func DeleteCategory(c *gin.Context) {
-	// Get user ID from context
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	ws, ok := currentWorkspace(c, models.RoleEditor)
+	if !ok {
 		return
 	}
 
@@ -133,20 +130,19 @@ func DeleteCategory(c *gin.Context) {
 	categoryID := c.Param("id")
 
 	var deleted []models.Category
-	err := config.SupaClient.DB.
+	q := config.DB.
 		From("categories").
 		Delete().
-		Eq("id", categoryID).
-		Eq("user_id", userID.(string)).
-		Execute(context.Background(), &deleted)
+		Eq("id", categoryID)
+	err := ws.scope(q).Execute(c.Request.Context(), &deleted)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete category: " + err.Error()})
+		apierror.Respond(c, fmt.Errorf("delete category: %w", err))
 		return
 	}
 
 	if len(deleted) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		apierror.Respond(c, apierror.ErrCategoryNotFound)
 		return
 	}
 
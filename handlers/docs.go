@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/openapi"
+)
+
+// OpenAPISpec serves the OpenAPI document of the API.
+func OpenAPISpec(c *gin.Context) {
+	c.JSON(http.StatusOK, openapi.Spec())
+}
+
+// APIDocs serves an interactive page for the document served by
+// OpenAPISpec.
+func APIDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.DocsPage)
+}
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lengzuo/supa/utils/enum"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/db"
+	"github.com/leo140803/finance-app-backend/export"
+	"github.com/leo140803/finance-app-backend/middleware"
+	"github.com/leo140803/finance-app-backend/models"
+)
+
+// exportPageSize is how many rows are pulled from Supabase per request
+// while streaming an export.
+const exportPageSize = 1000
+
+var (
+	transactionColumns = []string{"id", "date", "type", "amount", "balance_after", "account_id", "category_id", "description", "created_at"}
+	accountColumns     = []string{"id", "name", "initial_balance", "created_at"}
+	categoryColumns    = []string{"id", "name", "created_at"}
+)
+
+func transactionRow(tx models.Transaction) []any {
+	return []any{tx.ID, tx.Date, tx.Type, tx.Amount, tx.BalanceAfter, tx.AccountID, tx.CategoryID, tx.Description, tx.CreatedAt}
+}
+
+func accountRow(acc models.Account) []any {
+	return []any{acc.ID, acc.Name, acc.InitialBalance, acc.CreatedAt}
+}
+
+func categoryRow(cat models.Category) []any {
+	return []any{cat.ID, cat.Name, cat.CreatedAt}
+}
+
+// applyTransactionFilter narrows a transactions query to the optional
+// filters accepted by GET /transactions and the export endpoints.
+func applyTransactionFilter(q *db.SelectBuilder, f models.TransactionFilter) *db.SelectBuilder {
+	if f.StartDate != "" {
+		q.Gte("date", f.StartDate)
+	}
+	if f.EndDate != "" {
+		q.Lte("date", f.EndDate)
+	}
+	if f.AccountID != "" {
+		q.Eq("account_id", f.AccountID)
+	}
+	if f.CategoryID != "" {
+		q.Eq("category_id", f.CategoryID)
+	}
+	if f.Type != "" {
+		q.Eq("type", f.Type)
+	}
+	return q
+}
+
+// fetchTransactionPage loads rows [from, to] of the transactions selected
+// by scope, usually ws.scope or the result of transactionScope. Ordering
+// by id after date keeps pages stable when several transactions share a
+// date.
+func fetchTransactionPage(ctx context.Context, scope func(*db.FilterBuilder) *db.FilterBuilder, f models.TransactionFilter, from, to int) ([]models.Transaction, error) {
+	var page []models.Transaction
+	q := config.DB.From("transactions").Select("*").
+		Order("date.asc,id", enum.OrderAsc).
+		Range(from, to)
+	scope(&q.FilterBuilder)
+	err := applyTransactionFilter(q, f).Execute(ctx, &page)
+	return page, err
+}
+
+// streamExport writes every page returned by fetch as a downloadable file.
+// The first page is loaded before any headers are sent so that storage
+// errors can still be reported as a normal JSON error response.
+func streamExport[T any](c *gin.Context, name string, columns []string, fetch func(from, to int) ([]T, error), row func(T) []any) {
+	format, err := export.ParseFormat(c.Query("format"))
+	if err != nil {
+		apierror.Respond(c, apierror.ErrValidation.Wrap(err).WithFields(apierror.FieldError{Field: "format", Rule: "oneof", Param: "csv json xlsx"}))
+		return
+	}
+
+	page, err := fetch(0, exportPageSize-1)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch %s: %w", name, err))
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("2006-01-02"), format)
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	w, err := export.NewWriter(format, c.Writer, columns)
+	if err != nil {
+		middleware.Log(c).Error("export failed", "export", name, "error", err)
+		return
+	}
+
+	for offset := 0; ; offset += exportPageSize {
+		for _, item := range page {
+			if err := w.WriteRow(row(item)...); err != nil {
+				middleware.Log(c).Error("export failed", "export", name, "error", err)
+				return
+			}
+		}
+		c.Writer.Flush()
+		if len(page) < exportPageSize {
+			break
+		}
+		next := offset + exportPageSize
+		page, err = fetch(next, next+exportPageSize-1)
+		if err != nil {
+			// Headers are already sent; truncate the download rather than
+			// pretend it completed.
+			middleware.Log(c).Error("export truncated", "export", name, "offset", next, "error", err)
+			return
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		middleware.Log(c).Error("export failed", "export", name, "error", err)
+	}
+}
+
+func ExportTransactions(c *gin.Context) {
+	ws, ok := currentWorkspace(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	var filter models.TransactionFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	// Like GET /transactions, include accounts shared with the user
+	scope, err := transactionScope(c.Request.Context(), ws)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch transactions: %w", err))
+		return
+	}
+
+	streamExport(c, "transactions", transactionColumns, func(from, to int) ([]models.Transaction, error) {
+		return fetchTransactionPage(c.Request.Context(), scope, filter, from, to)
+	}, transactionRow)
+}
+
+func ExportAccounts(c *gin.Context) {
+	ws, ok := currentWorkspace(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	streamExport(c, "accounts", accountColumns, func(from, to int) ([]models.Account, error) {
+		var accounts []models.Account
+		q := config.DB.From("accounts").Select("*").
+			Order("id", enum.OrderAsc).
+			Range(from, to)
+		err := ws.scope(&q.FilterBuilder).Execute(c.Request.Context(), &accounts)
+		return accounts, err
+	}, accountRow)
+}
+
+func ExportCategories(c *gin.Context) {
+	ws, ok := currentWorkspace(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	streamExport(c, "categories", categoryColumns, func(from, to int) ([]models.Category, error) {
+		var categories []models.Category
+		q := config.DB.From("categories").Select("*").
+			Order("id", enum.OrderAsc).
+			Range(from, to)
+		err := ws.scope(&q.FilterBuilder).Execute(c.Request.Context(), &categories)
+		return categories, err
+	}, categoryRow)
+}
+
+// ExportArchive streams a models.Archive containing everything in the
+// workspace. Accounts and categories are small and loaded up front; transactions
+// are written page by page.
+func ExportArchive(c *gin.Context) {
+	ws, ok := currentWorkspace(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	var accounts []models.Account
+	q := config.DB.From("accounts").Select("*")
+	err := ws.scope(&q.FilterBuilder).Execute(ctx, &accounts)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch accounts: %w", err))
+		return
+	}
+
+	var categories []models.Category
+	q = config.DB.From("categories").Select("*")
+	err = ws.scope(&q.FilterBuilder).Execute(ctx, &categories)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch categories: %w", err))
+		return
+	}
+
+	page, err := fetchTransactionPage(ctx, ws.scope, models.TransactionFilter{}, 0, exportPageSize-1)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch transactions: %w", err))
+		return
+	}
+
+	if accounts == nil {
+		accounts = []models.Account{}
+	}
+	if categories == nil {
+		categories = []models.Category{}
+	}
+
+	filename := fmt.Sprintf("duitku-archive-%s.json", time.Now().Format("2006-01-02"))
+	c.Header("Content-Type", "application/json")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	// Write the keys of models.Archive one by one, leaving the
+	// transactions array open so it can be filled in as pages arrive.
+	enc := json.NewEncoder(c.Writer)
+	fields := []struct {
+		key   string
+		value any
+	}{
+		{"version", models.ArchiveVersion},
+		{"exported_at", time.Now().UTC().Format(time.RFC3339)},
+		{"accounts", accounts},
+		{"categories", categories},
+	}
+	c.Writer.WriteString("{")
+	for _, f := range fields {
+		c.Writer.WriteString(`"` + f.key + `":`)
+		if err := enc.Encode(f.value); err != nil {
+			middleware.Log(c).Error("export failed", "export", "archive", "error", err)
+			return
+		}
+		c.Writer.WriteString(",")
+	}
+	c.Writer.WriteString(`"transactions":[`)
+
+	written := 0
+	for offset := 0; ; offset += exportPageSize {
+		for _, tx := range page {
+			if written > 0 {
+				c.Writer.WriteString(",")
+			}
+			if err := enc.Encode(tx); err != nil {
+				middleware.Log(c).Error("export failed", "export", "archive", "error", err)
+				return
+			}
+			written++
+		}
+		c.Writer.Flush()
+		if len(page) < exportPageSize {
+			break
+		}
+		next := offset + exportPageSize
+		page, err = fetchTransactionPage(ctx, ws.scope, models.TransactionFilter{}, next, next+exportPageSize-1)
+		if err != nil {
+			middleware.Log(c).Error("export truncated", "export", "archive", "offset", next, "error", err)
+			return
+		}
+	}
+	c.Writer.WriteString("]}")
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lengzuo/supa/pkg/logger"
+	"github.com/lengzuo/supa/postgres"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/db"
+	"github.com/leo140803/finance-app-backend/models"
+)
+
+// fakeTables answers selects with the rows of the requested table.
+func fakeTables(t *testing.T, tables map[string]string) {
+	t.Helper()
+	logger.New(false)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rows, ok := tables[strings.TrimPrefix(r.URL.Path, "/rest/v1/")]
+		if r.Method != http.MethodGet || !ok {
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(rows))
+	}))
+	t.Cleanup(srv.Close)
+
+	prev := config.DB
+	config.DB = db.New(postgres.New(srv.URL + "/rest/v1"))
+	t.Cleanup(func() { config.DB = prev })
+}
+
+func TestExportArchive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name         string
+		transactions string
+		want         int
+	}{
+		{"no transactions", `[]`, 0},
+		{"transactions", `[{"id":"tx-1","account_id":"acc-1"},{"id":"tx-2","account_id":"acc-1"}]`, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeTables(t, map[string]string{
+				"accounts":     `[{"id":"acc-1","name":"Cash \"main\""}]`,
+				"categories":   `[]`,
+				"transactions": tt.transactions,
+			})
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/export/archive", nil)
+			c.Set("user_id", "user-1")
+
+			ExportArchive(c)
+
+			var archive models.Archive
+			dec := json.NewDecoder(w.Body)
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&archive); err != nil {
+				t.Fatalf("archive is not valid JSON: %v", err)
+			}
+			if archive.Version != models.ArchiveVersion || archive.ExportedAt == "" {
+				t.Errorf("version = %d, exported_at = %q", archive.Version, archive.ExportedAt)
+			}
+			if len(archive.Accounts) != 1 || archive.Accounts[0].Name != `Cash "main"` {
+				t.Errorf("accounts = %+v", archive.Accounts)
+			}
+			if archive.Categories == nil || len(archive.Transactions) != tt.want {
+				t.Errorf("categories = %v, %d transactions, want %d", archive.Categories, len(archive.Transactions), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/jobs"
+	"github.com/leo140803/finance-app-backend/middleware"
+	"github.com/leo140803/finance-app-backend/models"
+)
+
+// readinessTimeout bounds the storage check, so a hanging Supabase fails
+// the probe instead of stalling it.
+const readinessTimeout = 3 * time.Second
+
+// Liveness only reports that the process is up and serving requests. It
+// checks nothing else, so a Supabase outage does not get the instance
+// restarted.
+func Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "ok",
+		"message": "service is healthy",
+	})
+}
+
+// Readiness reports whether the instance can serve traffic: Supabase
+// answers a query and the background job scheduler is running. It responds
+// 503 when either check fails.
+func Readiness(scheduler *jobs.Scheduler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ready := true
+
+		storage := gin.H{"status": "ok"}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+		start := time.Now()
+		var users []models.User
+		err := config.DB.From("users").Select("id").Limit(1).Execute(ctx, &users)
+		storage["duration_ms"] = time.Since(start).Milliseconds()
+		if err != nil {
+			ready = false
+			storage["status"] = "unavailable"
+			middleware.Log(c).Warn("readiness storage check failed", "error", err)
+		}
+
+		sched := gin.H{"status": "ok", "jobs": scheduler.Status()}
+		if !scheduler.Running() {
+			ready = false
+			sched["status"] = "stopped"
+		}
+
+		status, code := "ready", http.StatusOK
+		if !ready {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+		c.JSON(code, gin.H{
+			"status": status,
+			"checks": gin.H{"storage": storage, "scheduler": sched},
+		})
+	}
+}
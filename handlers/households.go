@@ -0,0 +1,522 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/mailer"
+	"github.com/leo140803/finance-app-backend/middleware"
+	"github.com/leo140803/finance-app-backend/models"
+)
+
+// invitationTTL is how long an emailed household invitation stays valid.
+const invitationTTL = 7 * 24 * time.Hour
+
+// householdTables are the tables whose rows can belong to a household, in
+// an order that respects foreign keys.
+var householdTables = []string{"transactions", "accounts", "categories"}
+
+// householdAccess checks that the user's role in the household named by the
+// :id route parameter is at least minRole. Otherwise it writes the error
+// response and returns false.
+func householdAccess(c *gin.Context, minRole string) (string, string, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return "", "", false
+	}
+
+	householdID := c.Param("id")
+	role, err := middleware.HouseholdRole(c.Request.Context(), householdID, userID.(string))
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("check household membership: %w", err))
+		return "", "", false
+	}
+	if role == "" {
+		apierror.Respond(c, apierror.ErrHouseholdNotFound)
+		return "", "", false
+	}
+	if roleRank[role] < roleRank[minRole] {
+		apierror.Respond(c, apierror.ErrRoleForbidden)
+		return "", "", false
+	}
+	return householdID, role, true
+}
+
+func householdMembers(ctx context.Context, householdID string) ([]models.HouseholdMember, error) {
+	var members []models.HouseholdMember
+	err := config.DB.From("household_members").Select("*").Eq("household_id", householdID).Execute(ctx, &members)
+	return members, err
+}
+
+// isLastOwner reports whether userID is the only owner among members.
+func isLastOwner(members []models.HouseholdMember, userID string) bool {
+	owners := 0
+	last := false
+	for _, m := range members {
+		if m.Role == models.RoleOwner {
+			owners++
+			last = m.UserID == userID
+		}
+	}
+	return owners == 1 && last
+}
+
+// deleteHouseholdData removes a household together with everything it
+// owns.
+func deleteHouseholdData(ctx context.Context, householdID string) error {
+	for _, table := range householdTables {
+		if err := config.DB.From(table).Delete().Eq("household_id", householdID).Execute(ctx, nil); err != nil {
+			return fmt.Errorf("delete %s: %w", table, err)
+		}
+	}
+	for _, table := range []string{"household_invitations", "household_members"} {
+		if err := config.DB.From(table).Delete().Eq("household_id", householdID).Execute(ctx, nil); err != nil {
+			return fmt.Errorf("delete %s: %w", table, err)
+		}
+	}
+	return config.DB.From("households").Delete().Eq("id", householdID).Execute(ctx, nil)
+}
+
+func GetHouseholds(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+	ctx := c.Request.Context()
+
+	var memberships []models.HouseholdMember
+	err := config.DB.From("household_members").Select("*").Eq("user_id", userID.(string)).Execute(ctx, &memberships)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch households: %w", err))
+		return
+	}
+
+	households := []models.Household{}
+	if len(memberships) == 0 {
+		c.JSON(http.StatusOK, households)
+		return
+	}
+
+	roles := make(map[string]string, len(memberships))
+	ids := make([]string, 0, len(memberships))
+	for _, m := range memberships {
+		roles[m.HouseholdID] = m.Role
+		ids = append(ids, m.HouseholdID)
+	}
+
+	err = config.DB.From("households").Select("*").In("id", ids).Execute(ctx, &households)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch households: %w", err))
+		return
+	}
+	for i := range households {
+		households[i].Role = roles[households[i].ID]
+	}
+
+	c.JSON(http.StatusOK, households)
+}
+
+func CreateHousehold(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	var req models.HouseholdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	ctx := c.Request.Context()
+
+	var household models.Household
+	err := config.DB.From("households").
+		Insert(models.Household{Name: req.Name, CreatedBy: userID.(string)}).
+		Execute(ctx, &household)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("create household: %w", err))
+		return
+	}
+
+	owner := models.HouseholdMember{HouseholdID: household.ID, UserID: userID.(string), Role: models.RoleOwner}
+	err = config.DB.From("household_members").Insert(owner).Execute(ctx, nil)
+	if err != nil {
+		config.DB.From("households").Delete().Eq("id", household.ID).Execute(ctx, nil)
+		apierror.Respond(c, fmt.Errorf("create household: %w", err))
+		return
+	}
+
+	household.Role = models.RoleOwner
+	c.JSON(http.StatusCreated, household)
+}
+
+func GetHousehold(c *gin.Context) {
+	householdID, role, ok := householdAccess(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+
+	var households []models.Household
+	err := config.DB.From("households").Select("*").Eq("id", householdID).Execute(ctx, &households)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch household: %w", err))
+		return
+	}
+	if len(households) == 0 {
+		apierror.Respond(c, apierror.ErrHouseholdNotFound)
+		return
+	}
+
+	members, err := householdMembers(ctx, householdID)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch members: %w", err))
+		return
+	}
+
+	ids := make([]string, len(members))
+	for i, m := range members {
+		ids[i] = m.UserID
+	}
+	var users []models.User
+	err = config.DB.From("users").Select("id,email").In("id", ids).Execute(ctx, &users)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch members: %w", err))
+		return
+	}
+	emails := make(map[string]string, len(users))
+	for _, u := range users {
+		emails[u.ID] = u.Email
+	}
+	for i := range members {
+		members[i].Email = emails[members[i].UserID]
+	}
+
+	details := models.HouseholdDetails{Household: households[0], Members: members}
+	details.Role = role
+	c.JSON(http.StatusOK, details)
+}
+
+func UpdateHousehold(c *gin.Context) {
+	householdID, _, ok := householdAccess(c, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	var req models.HouseholdRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	var updated []models.Household
+	err := config.DB.From("households").
+		Update(map[string]string{"name": req.Name}).
+		Eq("id", householdID).
+		Execute(c.Request.Context(), &updated)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("update household: %w", err))
+		return
+	}
+
+	if len(updated) == 0 {
+		apierror.Respond(c, apierror.ErrHouseholdNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated[0])
+}
+
+// DeleteHousehold deletes the household and all of its accounts,
+// categories and transactions.
+func DeleteHousehold(c *gin.Context) {
+	householdID, _, ok := householdAccess(c, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	if err := deleteHouseholdData(c.Request.Context(), householdID); err != nil {
+		apierror.Respond(c, fmt.Errorf("delete household: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Household deleted successfully"})
+}
+
+func UpdateHouseholdMember(c *gin.Context) {
+	householdID, _, ok := householdAccess(c, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	ctx := c.Request.Context()
+	memberID := c.Param("user_id")
+
+	members, err := householdMembers(ctx, householdID)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch members: %w", err))
+		return
+	}
+	if req.Role != models.RoleOwner && isLastOwner(members, memberID) {
+		apierror.Respond(c, apierror.ErrLastOwner)
+		return
+	}
+
+	var updated []models.HouseholdMember
+	err = config.DB.From("household_members").
+		Update(map[string]string{"role": req.Role}).
+		Eq("household_id", householdID).
+		Eq("user_id", memberID).
+		Execute(ctx, &updated)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("update member: %w", err))
+		return
+	}
+
+	if len(updated) == 0 {
+		apierror.Respond(c, apierror.ErrMemberNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, updated[0])
+}
+
+// RemoveHouseholdMember removes a member. Owners can remove anyone; every
+// member can remove themselves to leave the household.
+func RemoveHouseholdMember(c *gin.Context) {
+	householdID, role, ok := householdAccess(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	memberID := c.Param("user_id")
+
+	if memberID != c.GetString("user_id") && role != models.RoleOwner {
+		apierror.Respond(c, apierror.ErrRoleForbidden)
+		return
+	}
+
+	members, err := householdMembers(ctx, householdID)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch members: %w", err))
+		return
+	}
+	if isLastOwner(members, memberID) {
+		apierror.Respond(c, apierror.ErrLastOwner)
+		return
+	}
+
+	var deleted []models.HouseholdMember
+	err = config.DB.From("household_members").
+		Delete().
+		Eq("household_id", householdID).
+		Eq("user_id", memberID).
+		Execute(ctx, &deleted)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("remove member: %w", err))
+		return
+	}
+
+	if len(deleted) == 0 {
+		apierror.Respond(c, apierror.ErrMemberNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+// InviteHouseholdMember emails an invitation link. The invitee accepts it
+// with AcceptHouseholdInvitation after signing in with that email.
+func InviteHouseholdMember(c *gin.Context) {
+	householdID, _, ok := householdAccess(c, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	var req models.InviteMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	ctx := c.Request.Context()
+
+	var households []models.Household
+	err := config.DB.From("households").Select("*").Eq("id", householdID).Execute(ctx, &households)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch household: %w", err))
+		return
+	}
+	if len(households) == 0 {
+		apierror.Respond(c, apierror.ErrHouseholdNotFound)
+		return
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		apierror.Respond(c, fmt.Errorf("create invitation: %w", err))
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	invitation := models.HouseholdInvitation{
+		HouseholdID: householdID,
+		Email:       strings.ToLower(req.Email),
+		Role:        req.Role,
+		TokenHash:   middleware.HashToken(token),
+		InvitedBy:   c.GetString("user_id"),
+		ExpiresAt:   time.Now().UTC().Add(invitationTTL).Format(time.RFC3339),
+	}
+	var created models.HouseholdInvitation
+	err = config.DB.From("household_invitations").Insert(invitation).Execute(ctx, &created)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("create invitation: %w", err))
+		return
+	}
+
+	link := fmt.Sprintf("%s/households/join?token=%s", config.AppURL, url.QueryEscape(token))
+	err = config.Mailer.Send(ctx, mailer.Message{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("You have been invited to %s on Duitku", households[0].Name),
+		Body:    fmt.Sprintf("You have been invited to join the household %q as %s. Use the link below to accept:\n\n%s\n\nThe invitation expires in 7 days.", households[0].Name, req.Role, link),
+	})
+	if err != nil {
+		middleware.Log(c).Error("failed to send household invitation", "invitation_id", created.ID, "error", err)
+	}
+
+	created.TokenHash = ""
+	c.JSON(http.StatusCreated, created)
+}
+
+func GetHouseholdInvitations(c *gin.Context) {
+	householdID, _, ok := householdAccess(c, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	var invitations []models.HouseholdInvitation
+	err := config.DB.From("household_invitations").
+		Select("id,household_id,email,role,invited_by,expires_at,created_at").
+		Eq("household_id", householdID).
+		Is("accepted_at", "null").
+		Gte("expires_at", time.Now().UTC().Format(time.RFC3339)).
+		Execute(c.Request.Context(), &invitations)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch invitations: %w", err))
+		return
+	}
+
+	if invitations == nil {
+		invitations = []models.HouseholdInvitation{}
+	}
+	c.JSON(http.StatusOK, invitations)
+}
+
+func RevokeHouseholdInvitation(c *gin.Context) {
+	householdID, _, ok := householdAccess(c, models.RoleOwner)
+	if !ok {
+		return
+	}
+
+	var deleted []models.HouseholdInvitation
+	err := config.DB.From("household_invitations").
+		Delete().
+		Eq("id", c.Param("invitation_id")).
+		Eq("household_id", householdID).
+		Is("accepted_at", "null").
+		Execute(c.Request.Context(), &deleted)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("revoke invitation: %w", err))
+		return
+	}
+
+	if len(deleted) == 0 {
+		apierror.Respond(c, apierror.ErrInvitationNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+// AcceptHouseholdInvitation adds the user to the household of an emailed
+// invitation. The invitation only works for the email it was sent to.
+func AcceptHouseholdInvitation(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	var req models.AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	ctx := c.Request.Context()
+
+	var invitations []models.HouseholdInvitation
+	err := config.DB.From("household_invitations").Select("*").
+		Eq("token_hash", middleware.HashToken(req.Token)).
+		Is("accepted_at", "null").
+		Gte("expires_at", time.Now().UTC().Format(time.RFC3339)).
+		Execute(ctx, &invitations)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch invitation: %w", err))
+		return
+	}
+	if len(invitations) == 0 {
+		apierror.Respond(c, apierror.ErrInvitationInvalid)
+		return
+	}
+	invitation := invitations[0]
+
+	var users []models.User
+	err = config.DB.From("users").Select("*").Eq("id", userID.(string)).Execute(ctx, &users)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch user: %w", err))
+		return
+	}
+	if len(users) == 0 {
+		apierror.Respond(c, apierror.ErrUserNotFound)
+		return
+	}
+	if !strings.EqualFold(users[0].Email, invitation.Email) {
+		apierror.Respond(c, apierror.ErrInvitationEmailMismatch)
+		return
+	}
+
+	member := models.HouseholdMember{HouseholdID: invitation.HouseholdID, UserID: userID.(string), Role: invitation.Role}
+	err = config.DB.From("household_members").Insert(member).Execute(ctx, nil)
+	if isUniqueViolation(err) {
+		apierror.Respond(c, apierror.ErrAlreadyMember)
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("join household: %w", err))
+		return
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	config.DB.From("household_invitations").
+		Update(map[string]string{"accepted_at": now}).
+		Eq("id", invitation.ID).
+		Execute(ctx, nil)
+
+	c.JSON(http.StatusOK, member)
+}
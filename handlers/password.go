@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/mailer"
+	"github.com/leo140803/finance-app-backend/metrics"
+	"github.com/leo140803/finance-app-backend/middleware"
+	"github.com/leo140803/finance-app-backend/models"
+	"github.com/lengzuo/supa/dto"
+)
+
+// mailAuthLink mails a link to the frontend page that completes the flow
+// for a token created with config.AuthAdmin.GenerateLink.
+func mailAuthLink(ctx context.Context, link *config.GeneratedLink, page, subject, intro string) error {
+	target := fmt.Sprintf("%s/%s?token=%s", config.AppURL, page, url.QueryEscape(link.HashedToken))
+	return config.Mailer.Send(ctx, mailer.Message{
+		To:      link.Email,
+		Subject: subject,
+		Body:    intro + "\n\n" + target + "\n\nIf you did not request this, you can ignore this email.",
+	})
+}
+
+// checkPassword proves that the user knows their password by signing in
+// with it, and returns the new session. Failures count towards the same
+// lockout as Login, so it cannot be used to guess passwords. On failure it
+// has responded and returns nil.
+func checkPassword(c *gin.Context, email, password string) *dto.AuthDetailResp {
+	ctx := c.Request.Context()
+	lockKey := middleware.LoginLockKey(email)
+	if retryAfter, err := config.LoginLockout.Locked(ctx, lockKey); err == nil && retryAfter > 0 {
+		middleware.TooManyRequests(c, retryAfter, apierror.ErrTooManyLoginAttempts)
+		return nil
+	}
+
+	session, err := config.SupaClient.Auth.SignInWithPassword(ctx, dto.SignInRequest{
+		Email:    email,
+		Password: password,
+	})
+	if err != nil {
+		metrics.AuthFailures.WithLabelValues("incorrect_password").Inc()
+		if lockedFor, _ := config.LoginLockout.Fail(ctx, lockKey); lockedFor > 0 {
+			middleware.TooManyRequests(c, lockedFor, apierror.ErrTooManyLoginAttempts)
+			return nil
+		}
+		apierror.Respond(c, apierror.ErrIncorrectPassword.Wrap(err))
+		return nil
+	}
+	config.LoginLockout.Succeed(ctx, lockKey)
+	return session
+}
+
+// ForgotPassword mails a password reset link. It always answers with the
+// same message so it cannot be used to find out which emails are
+// registered.
+func ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	if config.AuthAdmin == nil {
+		apierror.Respond(c, apierror.ErrDisabled)
+		return
+	}
+	if !middleware.LimitEmail(c, middleware.ForgotPasswordLimit, req.Email, 3, time.Hour) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	link, err := config.AuthAdmin.GenerateLink(ctx, "recovery", req.Email)
+	if err == nil {
+		err = mailAuthLink(ctx, link, "reset-password",
+			"Reset your Duitku password", "Use the link below to choose a new password:")
+	}
+	if err != nil {
+		middleware.Log(c).Error("failed to send password reset email",
+			"email_hash_prefix", middleware.HashToken(strings.ToLower(req.Email))[:12], "error", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the email is registered, a reset link has been sent"})
+}
+
+// ResetPassword completes a reset with the token from the emailed link.
+func ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	ctx := c.Request.Context()
+
+	session, err := config.SupaClient.Auth.Verify(ctx, dto.VerifyRequest{
+		Type:      "recovery",
+		TokenHash: req.Token,
+	})
+	if err != nil {
+		apierror.Respond(c, apierror.ErrInvalidResetToken.Wrap(err))
+		return
+	}
+
+	_, err = config.SupaClient.Auth.UpdateUser(ctx, session.AccessToken, dto.UpdateUserRequest{Password: req.Password})
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("reset password: %w", err))
+		return
+	}
+
+	// Whoever knew the old password is signed out, and so is the session
+	// the reset link opened
+	var users []models.User
+	err = config.DB.From("users").Select("id").Eq("email", session.User.Email).Execute(ctx, &users)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch user: %w", err))
+		return
+	}
+	if len(users) > 0 {
+		if _, err := revokeUserSessions(ctx, users[0].ID, ""); err != nil {
+			apierror.Respond(c, fmt.Errorf("revoke sessions: %w", err))
+			return
+		}
+	}
+	if err := config.SignOut(ctx, session.AccessToken, "global"); err != nil {
+		apierror.Respond(c, fmt.Errorf("sign out in Supabase Auth: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
+// ChangePassword sets a new password for the logged-in user after checking
+// the current one.
+func ChangePassword(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	ctx := c.Request.Context()
+
+	var users []models.User
+	err := config.DB.From("users").Select("*").Eq("id", userID.(string)).Execute(ctx, &users)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch user: %w", err))
+		return
+	}
+	if len(users) == 0 {
+		apierror.Respond(c, apierror.ErrUserNotFound)
+		return
+	}
+
+	// Signing in again proves the current password and gives a fresh
+	// session to update the user with.
+	session := checkPassword(c, users[0].Email, req.CurrentPassword)
+	if session == nil {
+		return
+	}
+
+	_, err = config.SupaClient.Auth.UpdateUser(ctx, session.AccessToken, dto.UpdateUserRequest{Password: req.NewPassword})
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("change password: %w", err))
+		return
+	}
+
+	// Only the session making the change stays signed in; the one opened
+	// above to check the password ends with the others
+	if err := signOutOtherSessions(c, userID.(string), session.AccessToken); err != nil {
+		apierror.Respond(c, fmt.Errorf("sign out other sessions: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// ResendVerification mails a new email confirmation link to the
+// logged-in user.
+func ResendVerification(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+	if config.AuthAdmin == nil {
+		apierror.Respond(c, apierror.ErrDisabled)
+		return
+	}
+	ctx := c.Request.Context()
+
+	var users []models.User
+	err := config.DB.From("users").Select("*").Eq("id", userID.(string)).Execute(ctx, &users)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch user: %w", err))
+		return
+	}
+	if len(users) == 0 {
+		apierror.Respond(c, apierror.ErrUserNotFound)
+		return
+	}
+
+	// Verifying a magic link confirms the address of an unconfirmed user.
+	link, err := config.AuthAdmin.GenerateLink(ctx, "magiclink", users[0].Email)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("create verification link: %w", err))
+		return
+	}
+	if link.EmailConfirmedAt != "" {
+		apierror.Respond(c, apierror.ErrEmailAlreadyVerified)
+		return
+	}
+
+	err = mailAuthLink(ctx, link, "verify-email",
+		"Confirm your Duitku email address", "Use the link below to confirm your email address:")
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("send verification email: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+}
+
+// VerifyEmail confirms an email address with the token from the emailed
+// link.
+func VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	_, err := config.SupaClient.Auth.Verify(c.Request.Context(), dto.VerifyRequest{
+		Type:      "magiclink",
+		TokenHash: req.Token,
+	})
+	if err != nil {
+		apierror.Respond(c, apierror.ErrInvalidVerifyToken.Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+}
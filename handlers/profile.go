@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/models"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// preferences are the profile settings that change how dates are handled.
+type preferences struct {
+	loc       *time.Location
+	weekStart time.Weekday
+}
+
+// loadLocation is time.LoadLocation for time zone names users send. It
+// refuses "Local", which is the server's zone rather than a real one, like
+// the timezone binding does.
+func loadLocation(name string) (*time.Location, error) {
+	if strings.EqualFold(name, "local") {
+		return nil, fmt.Errorf("unknown time zone %s", name)
+	}
+	return time.LoadLocation(name)
+}
+
+// userPreferences loads the user's time zone and first day of the week.
+// Users who never set them get UTC and Monday.
+func userPreferences(ctx context.Context, userID string) (preferences, error) {
+	prefs := preferences{loc: time.UTC, weekStart: time.Monday}
+
+	var users []models.User
+	err := config.DB.From("users").Select("timezone,week_start").Eq("id", userID).Execute(ctx, &users)
+	if err != nil || len(users) == 0 {
+		return prefs, err
+	}
+
+	if tz := users[0].Timezone; tz != "" {
+		if loc, err := loadLocation(tz); err == nil {
+			prefs.loc = loc
+		}
+	}
+	if day, ok := weekdays[users[0].WeekStart]; ok {
+		prefs.weekStart = day
+	}
+	return prefs, nil
+}
+
+func UpdateProfile(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	var req models.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	ctx := c.Request.Context()
+
+	if req.DefaultAccountID != nil && *req.DefaultAccountID != "" {
+		var accounts []models.Account
+		err := config.DB.From("accounts").Select("id").
+			Eq("id", *req.DefaultAccountID).
+			Eq("user_id", userID.(string)).
+			Execute(ctx, &accounts)
+		if err != nil {
+			apierror.Respond(c, fmt.Errorf("fetch account: %w", err))
+			return
+		}
+		if len(accounts) == 0 {
+			apierror.Respond(c, apierror.ErrValidation.WithFields(apierror.FieldError{Field: "default_account_id", Rule: "exists"}))
+			return
+		}
+	}
+
+	if req == (models.UpdateProfileRequest{}) {
+		GetProfile(c)
+		return
+	}
+
+	var users []models.User
+	err := config.DB.From("users").
+		Update(req).
+		Eq("id", userID.(string)).
+		Execute(ctx, &users)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("update profile: %w", err))
+		return
+	}
+
+	if len(users) == 0 {
+		apierror.Respond(c, apierror.ErrUserNotFound)
+		return
+	}
+
+	c.JSON(http.StatusOK, users[0])
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import "testing"
+
+func TestLoadLocation(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"Asia/Jakarta", false},
+		{"UTC", false},
+		{"Local", true},
+		{"local", true},
+		{"Mars/Olympus", true},
+	}
+	for _, tt := range tests {
+		if _, err := loadLocation(tt.name); (err != nil) != tt.wantErr {
+			t.Errorf("loadLocation(%q) error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,469 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/models"
+)
+
+const dateLayout = "2006-01-02"
+
+// parseReportRange resolves the ?month= or ?start_date=&end_date= query of
+// a report into an inclusive date range. The default range is the current
+// month as seen from loc.
+func parseReportRange(c *gin.Context, loc *time.Location) (time.Time, time.Time, error) {
+	var q models.ReportRange
+	if err := c.ShouldBindQuery(&q); err != nil {
+		return time.Time{}, time.Time{}, apierror.Invalid(err)
+	}
+
+	if q.Month != "" {
+		if q.StartDate != "" || q.EndDate != "" {
+			return time.Time{}, time.Time{}, apierror.ErrValidation.WithFields(apierror.FieldError{
+				Field: "month", Rule: "excluded_with", Param: "start_date end_date",
+			})
+		}
+		start, _ := time.Parse("2006-01", q.Month)
+		return start, start.AddDate(0, 1, -1), nil
+	}
+
+	if q.StartDate == "" && q.EndDate == "" {
+		now := time.Now().In(loc)
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, -1), nil
+	}
+	if q.StartDate == "" {
+		return time.Time{}, time.Time{}, apierror.ErrValidation.WithFields(apierror.FieldError{Field: "start_date", Rule: "required"})
+	}
+	if q.EndDate == "" {
+		return time.Time{}, time.Time{}, apierror.ErrValidation.WithFields(apierror.FieldError{Field: "end_date", Rule: "required"})
+	}
+
+	start, _ := time.Parse(dateLayout, q.StartDate)
+	end, _ := time.Parse(dateLayout, q.EndDate)
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, apierror.ErrValidation.WithFields(apierror.FieldError{
+			Field: "end_date", Rule: "gtefield", Param: "start_date",
+		})
+	}
+	return start, end, nil
+}
+
+// eachTransaction pages through the workspace's transactions matching
+// filter in date order and calls fn for every row.
+func eachTransaction(ctx context.Context, ws workspace, filter models.TransactionFilter, fn func(models.Transaction)) error {
+	for offset := 0; ; offset += exportPageSize {
+		page, err := fetchTransactionPage(ctx, ws.scope, filter, offset, offset+exportPageSize-1)
+		if err != nil {
+			return err
+		}
+		for _, tx := range page {
+			fn(tx)
+		}
+		if len(page) < exportPageSize {
+			return nil
+		}
+	}
+}
+
+// signedAmount returns the transaction amount as it affects the account
+// balance: positive for income, negative for expenses.
+func signedAmount(tx models.Transaction) float64 {
+	if tx.Type == "INCOME" {
+		return tx.Amount
+	}
+	return -tx.Amount
+}
+
+func addToBreakdown(b *models.ReportBreakdown, tx models.Transaction) {
+	if tx.Type == "INCOME" {
+		b.Income += tx.Amount
+	} else {
+		b.Expense += tx.Amount
+	}
+	b.Net = b.Income - b.Expense
+	b.Count++
+}
+
+// sortedBreakdown returns the breakdown entries ordered by total volume,
+// largest first.
+func sortedBreakdown(m map[string]*models.ReportBreakdown) []models.ReportBreakdown {
+	out := make([]models.ReportBreakdown, 0, len(m))
+	for _, b := range m {
+		out = append(out, *b)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		vi, vj := out[i].Income+out[i].Expense, out[j].Income+out[j].Expense
+		if vi != vj {
+			return vi > vj
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+func GetSummaryReport(c *gin.Context) {
+	ws, ok := currentWorkspace(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	prefs, err := userPreferences(ctx, ws.userID)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch profile: %w", err))
+		return
+	}
+
+	start, end, err := parseReportRange(c, prefs.loc)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	var accounts []models.Account
+	q := config.DB.From("accounts").Select("*")
+	err = ws.scope(&q.FilterBuilder).Execute(ctx, &accounts)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch accounts: %w", err))
+		return
+	}
+	accountNames := make(map[string]string, len(accounts))
+	for _, acc := range accounts {
+		accountNames[acc.ID] = acc.Name
+	}
+
+	var categories []models.Category
+	q = config.DB.From("categories").Select("*")
+	err = ws.scope(&q.FilterBuilder).Execute(ctx, &categories)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch categories: %w", err))
+		return
+	}
+	categoryNames := make(map[string]string, len(categories))
+	for _, cat := range categories {
+		categoryNames[cat.ID] = cat.Name
+	}
+
+	report := models.SummaryReport{
+		StartDate: start.Format(dateLayout),
+		EndDate:   end.Format(dateLayout),
+	}
+	byCategory := map[string]*models.ReportBreakdown{}
+	byAccount := map[string]*models.ReportBreakdown{}
+
+	filter := models.TransactionFilter{StartDate: report.StartDate, EndDate: report.EndDate}
+	err = eachTransaction(ctx, ws, filter, func(tx models.Transaction) {
+		if tx.Type == "INCOME" {
+			report.TotalIncome += tx.Amount
+		} else {
+			report.TotalExpense += tx.Amount
+		}
+
+		cat, ok := byCategory[tx.CategoryID]
+		if !ok {
+			name := categoryNames[tx.CategoryID]
+			if tx.CategoryID == "" {
+				name = "Uncategorized"
+			}
+			cat = &models.ReportBreakdown{ID: tx.CategoryID, Name: name}
+			byCategory[tx.CategoryID] = cat
+		}
+		addToBreakdown(cat, tx)
+
+		acc, ok := byAccount[tx.AccountID]
+		if !ok {
+			acc = &models.ReportBreakdown{ID: tx.AccountID, Name: accountNames[tx.AccountID]}
+			byAccount[tx.AccountID] = acc
+		}
+		addToBreakdown(acc, tx)
+	})
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch transactions: %w", err))
+		return
+	}
+
+	report.Net = report.TotalIncome - report.TotalExpense
+	report.ByCategory = sortedBreakdown(byCategory)
+	report.ByAccount = sortedBreakdown(byAccount)
+
+	c.JSON(http.StatusOK, report)
+}
+
+// bucketStart returns the first day of the interval bucket containing d.
+// Weeks start on weekStart.
+func bucketStart(d time.Time, interval string, weekStart time.Weekday) time.Time {
+	switch interval {
+	case "week":
+		offset := (int(d.Weekday()) - int(weekStart) + 7) % 7
+		return d.AddDate(0, 0, -offset)
+	case "month":
+		return time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, d.Location())
+	case "year":
+		return time.Date(d.Year(), 1, 1, 0, 0, 0, 0, d.Location())
+	}
+	return d
+}
+
+// nextBucket returns the start of the bucket following the one starting at t.
+func nextBucket(t time.Time, interval string) time.Time {
+	switch interval {
+	case "week":
+		return t.AddDate(0, 0, 7)
+	case "month":
+		return t.AddDate(0, 1, 0)
+	case "year":
+		return t.AddDate(1, 0, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// maxSeriesPoints keeps a daily series over many years from producing an
+// unbounded response.
+const maxSeriesPoints = 1000
+
+// timeSeries is the resolved period and buckets of a time-series report.
+type timeSeries struct {
+	interval   string
+	loc        *time.Location
+	weekStart  time.Weekday
+	start, end time.Time
+	buckets    []time.Time
+}
+
+// period returns the days the bucket starting at t covers, clipped to the
+// report range: the first and last week, month or year are usually partial.
+func (s *timeSeries) period(t time.Time) (time.Time, time.Time) {
+	start, end := t, nextBucket(t, s.interval).AddDate(0, 0, -1)
+	if start.Before(s.start) {
+		start = s.start
+	}
+	if end.After(s.end) {
+		end = s.end
+	}
+	return start, end
+}
+
+// parseSeries resolves the interval, time zone and date range shared by the
+// time-series reports. Transaction dates are calendar dates in the user's
+// time zone (new transactions default to "today" there), so they are
+// grouped as they are stored, without conversion; the time zone decides
+// what "today" and "this month" mean. Without ?tz= the time zone from the
+// user's profile is used.
+func parseSeries(c *gin.Context, interval, tz string, prefs preferences) (*timeSeries, error) {
+	if interval == "" {
+		interval = "month"
+	}
+	loc := prefs.loc
+	if tz != "" {
+		var err error
+		if loc, err = loadLocation(tz); err != nil {
+			return nil, apierror.ErrValidation.WithFields(apierror.FieldError{Field: "tz", Rule: "timezone"})
+		}
+	}
+
+	start, end, err := parseReportRange(c, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &timeSeries{interval: interval, loc: loc, weekStart: prefs.weekStart, start: start, end: end}
+	for t := bucketStart(start, interval, s.weekStart); !t.After(end); t = nextBucket(t, interval) {
+		if len(s.buckets) == maxSeriesPoints {
+			return nil, apierror.ErrValidation.WithFields(apierror.FieldError{
+				Field: "interval", Rule: "max_points", Param: strconv.Itoa(maxSeriesPoints),
+			})
+		}
+		s.buckets = append(s.buckets, t)
+	}
+	return s, nil
+}
+
+func GetCashFlowReport(c *gin.Context) {
+	ws, ok := currentWorkspace(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	var q models.CashFlowQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	ctx := c.Request.Context()
+	prefs, err := userPreferences(ctx, ws.userID)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch profile: %w", err))
+		return
+	}
+	series, err := parseSeries(c, q.Interval, q.Timezone, prefs)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	start, end := series.start, series.end
+
+	points := make([]models.CashFlowPoint, len(series.buckets))
+	index := map[string]int{}
+	for i, t := range series.buckets {
+		index[t.Format(dateLayout)] = i
+		periodStart, periodEnd := series.period(t)
+		points[i] = models.CashFlowPoint{
+			PeriodStart: periodStart.Format(dateLayout),
+			PeriodEnd:   periodEnd.Format(dateLayout),
+		}
+	}
+
+	filter := models.TransactionFilter{
+		StartDate:  start.Format(dateLayout),
+		EndDate:    end.Format(dateLayout),
+		AccountID:  q.AccountID,
+		CategoryID: q.CategoryID,
+	}
+	err = eachTransaction(ctx, ws, filter, func(tx models.Transaction) {
+		d, err := time.Parse(dateLayout, tx.Date)
+		if err != nil {
+			return
+		}
+		i, ok := index[bucketStart(d, series.interval, series.weekStart).Format(dateLayout)]
+		if !ok {
+			return
+		}
+		if tx.Type == "INCOME" {
+			points[i].Income += tx.Amount
+		} else {
+			points[i].Expense += tx.Amount
+		}
+		points[i].Net = points[i].Income - points[i].Expense
+	})
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch transactions: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.CashFlowReport{
+		Interval:  series.interval,
+		Timezone:  series.loc.String(),
+		WeekStart: strings.ToLower(series.weekStart.String()),
+		StartDate: start.Format(dateLayout),
+		EndDate:   end.Format(dateLayout),
+		Points:    points,
+	})
+}
+
+// GetNetWorthReport replays the transaction ledger to reconstruct every
+// account's balance at the end of each period. The stored account balance
+// is the current one, so the balance before the range is the current
+// balance minus everything booked from the start of the range onwards.
+func GetNetWorthReport(c *gin.Context) {
+	ws, ok := currentWorkspace(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	var q models.CashFlowQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	ctx := c.Request.Context()
+	prefs, err := userPreferences(ctx, ws.userID)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch profile: %w", err))
+		return
+	}
+	series, err := parseSeries(c, q.Interval, q.Timezone, prefs)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	var accounts []models.Account
+	accountsQuery := config.DB.From("accounts").Select("*")
+	err = ws.scope(&accountsQuery.FilterBuilder).Execute(ctx, &accounts)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch accounts: %w", err))
+		return
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Name < accounts[j].Name })
+
+	index := map[string]int{}
+	for i, t := range series.buckets {
+		index[t.Format(dateLayout)] = i
+	}
+
+	// since[acc] is the net effect of every transaction from the start of
+	// the range until today; deltas[i][acc] only covers bucket i.
+	since := map[string]float64{}
+	deltas := make([]map[string]float64, len(series.buckets))
+	for i := range deltas {
+		deltas[i] = map[string]float64{}
+	}
+
+	filter := models.TransactionFilter{StartDate: series.start.Format(dateLayout)}
+	err = eachTransaction(ctx, ws, filter, func(tx models.Transaction) {
+		amount := signedAmount(tx)
+		since[tx.AccountID] += amount
+
+		d, err := time.Parse(dateLayout, tx.Date)
+		if err != nil || d.After(series.end) {
+			return
+		}
+		if i, ok := index[bucketStart(d, series.interval, series.weekStart).Format(dateLayout)]; ok {
+			deltas[i][tx.AccountID] += amount
+		}
+	})
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch transactions: %w", err))
+		return
+	}
+
+	balances := make(map[string]float64, len(accounts))
+	for _, acc := range accounts {
+		balances[acc.ID] = acc.InitialBalance - since[acc.ID]
+	}
+
+	points := make([]models.NetWorthPoint, len(series.buckets))
+	for i, t := range series.buckets {
+		_, periodEnd := series.period(t)
+
+		point := models.NetWorthPoint{
+			Date:     periodEnd.Format(dateLayout),
+			Accounts: make([]models.AccountBalance, 0, len(accounts)),
+		}
+		for _, acc := range accounts {
+			balances[acc.ID] += deltas[i][acc.ID]
+			balance := balances[acc.ID]
+			if balance >= 0 {
+				point.Assets += balance
+			} else {
+				point.Liabilities -= balance
+			}
+			point.Accounts = append(point.Accounts, models.AccountBalance{
+				AccountID: acc.ID,
+				Name:      acc.Name,
+				Balance:   balance,
+			})
+		}
+		point.NetWorth = point.Assets - point.Liabilities
+		points[i] = point
+	}
+
+	c.JSON(http.StatusOK, models.NetWorthReport{
+		Interval:  series.interval,
+		Timezone:  series.loc.String(),
+		WeekStart: strings.ToLower(series.weekStart.String()),
+		StartDate: series.start.Format(dateLayout),
+		EndDate:   series.end.Format(dateLayout),
+		Points:    points,
+	})
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func date(t *testing.T, s string, loc *time.Location) time.Time {
+	t.Helper()
+	d, err := time.ParseInLocation(dateLayout, s, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestBucketStart(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone data not available:", err)
+	}
+
+	tests := []struct {
+		name      string
+		day       string
+		loc       *time.Location
+		interval  string
+		weekStart time.Weekday
+		want      string
+	}{
+		{"day", "2024-05-15", time.UTC, "day", time.Monday, "2024-05-15"},
+		{"week from monday", "2024-05-15", time.UTC, "week", time.Monday, "2024-05-13"},
+		{"week start day itself", "2024-05-13", time.UTC, "week", time.Monday, "2024-05-13"},
+		{"week from sunday", "2024-05-15", time.UTC, "week", time.Sunday, "2024-05-12"},
+		{"week from saturday", "2024-05-17", time.UTC, "week", time.Saturday, "2024-05-11"},
+		{"week across a year", "2025-01-01", time.UTC, "week", time.Monday, "2024-12-30"},
+		{"week across DST start", "2024-03-12", newYork, "week", time.Sunday, "2024-03-10"},
+		{"week across DST end", "2024-11-05", newYork, "week", time.Sunday, "2024-11-03"},
+		{"month", "2024-02-29", time.UTC, "month", time.Monday, "2024-02-01"},
+		{"month in DST zone", "2024-03-31", newYork, "month", time.Monday, "2024-03-01"},
+		{"year", "2024-07-04", time.UTC, "year", time.Monday, "2024-01-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bucketStart(date(t, tt.day, tt.loc), tt.interval, tt.weekStart)
+			if got.Format(dateLayout) != tt.want || got.Hour() != 0 {
+				t.Errorf("bucketStart = %s, want %s at midnight", got.Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+}
+
+func TestNextBucket(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone data not available:", err)
+	}
+
+	tests := []struct {
+		name     string
+		start    string
+		loc      *time.Location
+		interval string
+		want     string
+	}{
+		{"day", "2024-02-28", time.UTC, "day", "2024-02-29"},
+		{"day into DST", "2024-03-10", newYork, "day", "2024-03-11"},
+		{"day out of DST", "2024-11-03", newYork, "day", "2024-11-04"},
+		{"week", "2024-12-30", time.UTC, "week", "2025-01-06"},
+		{"week across DST start", "2024-03-10", newYork, "week", "2024-03-17"},
+		{"month", "2024-01-01", time.UTC, "month", "2024-02-01"},
+		{"month across DST end", "2024-10-01", newYork, "month", "2024-11-01"},
+		{"year", "2024-01-01", time.UTC, "year", "2025-01-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextBucket(date(t, tt.start, tt.loc), tt.interval)
+			if got.Format(dateLayout) != tt.want || got.Hour() != 0 {
+				t.Errorf("nextBucket = %s, want %s at midnight", got.Format(time.RFC3339), tt.want)
+			}
+		})
+	}
+}
+
+func TestSeriesPeriods(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		query     string
+		weekStart time.Weekday
+		want      [][2]string
+	}{
+		{"weeks clipped at both ends", "interval=week&start_date=2024-01-03&end_date=2024-01-20", time.Monday, [][2]string{
+			{"2024-01-03", "2024-01-07"},
+			{"2024-01-08", "2024-01-14"},
+			{"2024-01-15", "2024-01-20"},
+		}},
+		{"weeks from sunday", "interval=week&start_date=2024-01-03&end_date=2024-01-09", time.Sunday, [][2]string{
+			{"2024-01-03", "2024-01-06"},
+			{"2024-01-07", "2024-01-09"},
+		}},
+		{"months clipped", "interval=month&start_date=2024-01-15&end_date=2024-03-10", time.Monday, [][2]string{
+			{"2024-01-15", "2024-01-31"},
+			{"2024-02-01", "2024-02-29"},
+			{"2024-03-01", "2024-03-10"},
+		}},
+		{"single day", "interval=day&start_date=2024-03-10&end_date=2024-03-10", time.Monday, [][2]string{
+			{"2024-03-10", "2024-03-10"},
+		}},
+		{"year within a month", "interval=year&month=2024-02", time.Monday, [][2]string{
+			{"2024-02-01", "2024-02-29"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/reports/cash-flow?"+tt.query, nil)
+
+			s, err := parseSeries(c, c.Query("interval"), "", preferences{loc: time.UTC, weekStart: tt.weekStart})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(s.buckets) != len(tt.want) {
+				t.Fatalf("%d buckets, want %d", len(s.buckets), len(tt.want))
+			}
+			for i, b := range s.buckets {
+				start, end := s.period(b)
+				got := [2]string{start.Format(dateLayout), end.Format(dateLayout)}
+				if got != tt.want[i] {
+					t.Errorf("period %d = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/logging"
+	"github.com/leo140803/finance-app-backend/metrics"
+	"github.com/leo140803/finance-app-backend/models"
+)
+
+// maxArchiveSize caps the size of an uploaded archive.
+const maxArchiveSize = 50 << 20
+
+// maxStoredAmount is the largest amount a DECIMAL(10,2) column holds.
+const maxStoredAmount = 99999999.99
+
+// readArchive accepts either a multipart upload in the "file" field or the
+// archive as the raw JSON request body.
+func readArchive(c *gin.Context) (*models.Archive, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxArchiveSize)
+
+	var r io.Reader = c.Request.Body
+	if c.ContentType() == "multipart/form-data" {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return nil, apierror.ErrValidation.WithFields(apierror.FieldError{Field: "file", Rule: "required"})
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var archive models.Archive
+	if err := json.NewDecoder(r).Decode(&archive); err != nil {
+		return nil, apierror.Invalid(err)
+	}
+	return &archive, nil
+}
+
+// validateArchive checks that the archive can be restored as a whole: a
+// known version, unique IDs, transactions that only reference accounts
+// and categories contained in the same archive, and values the tables
+// accept. The stricter rules of the create endpoints, such as name lengths
+// or positive amounts, are not applied: records exported before a rule
+// was introduced must still restore.
+func validateArchive(a *models.Archive) []apierror.FieldError {
+	if a.Version != models.ArchiveVersion {
+		return []apierror.FieldError{{Field: "version", Rule: "eq", Param: strconv.Itoa(models.ArchiveVersion)}}
+	}
+
+	var errs []apierror.FieldError
+	amount := func(field string, v float64) {
+		if math.Abs(v) > maxStoredAmount {
+			errs = append(errs, apierror.FieldError{Field: field, Rule: "lte", Param: strconv.FormatFloat(maxStoredAmount, 'f', 2, 64)})
+		}
+	}
+
+	accounts := make(map[string]bool, len(a.Accounts))
+	for i, acc := range a.Accounts {
+		field := fmt.Sprintf("accounts[%d]", i)
+		switch {
+		case acc.ID == "":
+			errs = append(errs, apierror.FieldError{Field: field + ".id", Rule: "required"})
+		case accounts[acc.ID]:
+			errs = append(errs, apierror.FieldError{Field: field + ".id", Rule: "unique"})
+		}
+		accounts[acc.ID] = true
+		amount(field+".initial_balance", acc.InitialBalance)
+	}
+
+	categories := make(map[string]bool, len(a.Categories))
+	for i, cat := range a.Categories {
+		field := fmt.Sprintf("categories[%d]", i)
+		switch {
+		case cat.ID == "":
+			errs = append(errs, apierror.FieldError{Field: field + ".id", Rule: "required"})
+		case categories[cat.ID]:
+			errs = append(errs, apierror.FieldError{Field: field + ".id", Rule: "unique"})
+		}
+		categories[cat.ID] = true
+	}
+
+	for i, tx := range a.Transactions {
+		field := fmt.Sprintf("transactions[%d]", i)
+		switch {
+		case tx.AccountID == "":
+			errs = append(errs, apierror.FieldError{Field: field + ".account_id", Rule: "required"})
+		case !accounts[tx.AccountID]:
+			errs = append(errs, apierror.FieldError{Field: field + ".account_id", Rule: "exists"})
+		}
+		if tx.CategoryID != "" && !categories[tx.CategoryID] {
+			errs = append(errs, apierror.FieldError{Field: field + ".category_id", Rule: "exists"})
+		}
+		if tx.Date == "" {
+			errs = append(errs, apierror.FieldError{Field: field + ".date", Rule: "required"})
+		} else if _, err := time.Parse(time.DateOnly, tx.Date); err != nil {
+			errs = append(errs, apierror.FieldError{Field: field + ".date", Rule: "datetime", Param: time.DateOnly})
+		}
+		if tx.Type != "INCOME" && tx.Type != "EXPENSE" {
+			errs = append(errs, apierror.FieldError{Field: field + ".type", Rule: "oneof", Param: "INCOME EXPENSE"})
+		}
+		amount(field+".amount", tx.Amount)
+		amount(field+".balance_after", tx.BalanceAfter)
+	}
+	return errs
+}
+
+// RestoreArchive recreates the contents of an exported archive in the
+// current workspace. Records get new IDs and transactions are remapped to
+// the new account and category IDs. It runs as a dry run unless
+// ?dry_run=false is passed, so the archive can be checked first.
+func RestoreArchive(c *gin.Context) {
+	ws, ok := currentWorkspace(c, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	dryRun := true
+	if v := c.Query("dry_run"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			apierror.Respond(c, apierror.ErrValidation.WithFields(apierror.FieldError{
+				Field: "dry_run", Rule: "oneof", Param: "true false",
+			}))
+			return
+		}
+		dryRun = parsed
+	}
+
+	archive, err := readArchive(c)
+	if err != nil {
+		metrics.ImportsProcessed.WithLabelValues("invalid").Inc()
+		apierror.Respond(c, err)
+		return
+	}
+
+	result := models.RestoreResult{
+		DryRun:       dryRun,
+		Accounts:     len(archive.Accounts),
+		Categories:   len(archive.Categories),
+		Transactions: len(archive.Transactions),
+	}
+	if errs := validateArchive(archive); len(errs) > 0 {
+		metrics.ImportsProcessed.WithLabelValues("invalid").Inc()
+		apierror.Respond(c, apierror.ErrArchiveInvalid.WithFields(errs...))
+		return
+	}
+	if dryRun {
+		metrics.ImportsProcessed.WithLabelValues("dry_run").Inc()
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	if err := restoreArchive(c.Request.Context(), ws, archive); err != nil {
+		metrics.ImportsProcessed.WithLabelValues("failed").Inc()
+		apierror.Respond(c, fmt.Errorf("restore archive: %w", err))
+		return
+	}
+	metrics.ImportsProcessed.WithLabelValues("restored").Inc()
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// restoredTransaction is a transaction as restoreArchive inserts it. Every
+// row of a bulk insert must have the same keys, so optional columns are
+// sent as null rather than left out.
+type restoredTransaction struct {
+	UserID       string  `json:"user_id"`
+	HouseholdID  *string `json:"household_id"`
+	AccountID    string  `json:"account_id"`
+	CategoryID   *string `json:"category_id"`
+	Date         string  `json:"date"`
+	Description  string  `json:"description"`
+	Amount       float64 `json:"amount"`
+	Type         string  `json:"type"`
+	BalanceAfter float64 `json:"balance_after"`
+}
+
+// nullable returns nil for an empty string.
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// restoreArchive inserts the archive one table at a time, each in a
+// single request. There is no transaction across PostgREST calls, so on
+// failure the tables restored so far are emptied of the new rows again,
+// newest first.
+//
+// The rows go through Upsert because Insert asks PostgREST for a single
+// object. They carry no ids, so nothing can conflict, and PostgREST
+// returns them in the order they were sent.
+func restoreArchive(ctx context.Context, ws workspace, a *models.Archive) (err error) {
+	type created struct {
+		table string
+		ids   []string
+	}
+	var done []created
+	defer func() {
+		if err == nil {
+			return
+		}
+		// Roll back even when ctx was cancelled or timed out
+		ctx := context.WithoutCancel(ctx)
+		for i := len(done) - 1; i >= 0; i-- {
+			rows := done[i]
+			if len(rows.ids) == 0 {
+				continue
+			}
+			if delErr := config.DB.From(rows.table).Delete().
+				In("id", rows.ids).
+				Eq("user_id", ws.userID).
+				Execute(ctx, nil); delErr != nil {
+				logging.FromContext(ctx).Error("restore rollback failed", "table", rows.table, "rows", len(rows.ids), "error", delErr)
+			}
+		}
+	}()
+
+	accounts := make([]models.Account, len(a.Accounts))
+	for i, acc := range a.Accounts {
+		acc.ID, acc.CreatedAt, acc.UserID, acc.HouseholdID, acc.Access = "", "", ws.userID, ws.householdID, ""
+		accounts[i] = acc
+	}
+	ids, err := insertRows(ctx, "accounts", accounts, func(acc models.Account) string { return acc.ID })
+	done = append(done, created{"accounts", ids})
+	if err != nil {
+		return fmt.Errorf("accounts: %w", err)
+	}
+	accountIDs := make(map[string]string, len(ids))
+	for i, id := range ids {
+		accountIDs[a.Accounts[i].ID] = id
+	}
+
+	categories := make([]models.Category, len(a.Categories))
+	for i, cat := range a.Categories {
+		cat.ID, cat.CreatedAt, cat.UserID, cat.HouseholdID = "", "", ws.userID, ws.householdID
+		categories[i] = cat
+	}
+	ids, err = insertRows(ctx, "categories", categories, func(cat models.Category) string { return cat.ID })
+	done = append(done, created{"categories", ids})
+	if err != nil {
+		return fmt.Errorf("categories: %w", err)
+	}
+	categoryIDs := make(map[string]string, len(ids))
+	for i, id := range ids {
+		categoryIDs[a.Categories[i].ID] = id
+	}
+
+	// Balances are restored as they were: accounts carry their current
+	// balance and each transaction keeps its balance_after, so nothing is
+	// replayed here.
+	txs := make([]restoredTransaction, len(a.Transactions))
+	for i, tx := range a.Transactions {
+		txs[i] = restoredTransaction{
+			UserID:       ws.userID,
+			HouseholdID:  nullable(ws.householdID),
+			AccountID:    accountIDs[tx.AccountID],
+			CategoryID:   nullable(categoryIDs[tx.CategoryID]),
+			Date:         tx.Date,
+			Description:  tx.Description,
+			Amount:       tx.Amount,
+			Type:         tx.Type,
+			BalanceAfter: tx.BalanceAfter,
+		}
+	}
+	ids, err = insertRows(ctx, "transactions", txs, func(tx models.Transaction) string { return tx.ID })
+	done = append(done, created{"transactions", ids})
+	if err != nil {
+		return fmt.Errorf("transactions: %w", err)
+	}
+	return nil
+}
+
+// insertRows inserts rows into table in one request and returns the ids
+// of the created rows, read with id, in the order the rows were sent.
+// Getting a different number of rows back is an error, but the ids are
+// still returned so they can be rolled back.
+func insertRows[T, R any](ctx context.Context, table string, rows []T, id func(R) string) ([]string, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	var inserted []R
+	if err := config.DB.From(table).Upsert(rows).Execute(ctx, &inserted); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(inserted))
+	for i, row := range inserted {
+		ids[i] = id(row)
+	}
+	if len(ids) != len(rows) {
+		return ids, fmt.Errorf("inserted %d of %d rows", len(ids), len(rows))
+	}
+	return ids, nil
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lengzuo/supa/pkg/logger"
+	"github.com/lengzuo/supa/postgres"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/db"
+	"github.com/leo140803/finance-app-backend/models"
+)
+
+func TestRestoreArchive(t *testing.T) {
+	archive := &models.Archive{
+		Version:    models.ArchiveVersion,
+		Accounts:   []models.Account{{ID: "old-acc-1", Name: "Cash"}, {ID: "old-acc-2", Name: "Bank"}},
+		Categories: []models.Category{{ID: "old-cat-1", Name: "Food"}},
+		Transactions: []models.Transaction{
+			{AccountID: "old-acc-2", CategoryID: "old-cat-1", Date: "2024-01-02", Amount: 5, Type: "EXPENSE"},
+			{AccountID: "old-acc-1", Date: "2024-01-03", Description: "Salary", Amount: 9, Type: "INCOME"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		failOn   string
+		wantErr  bool
+		requests []string
+	}{
+		{"restored", "", false, []string{
+			"POST accounts", "POST categories", "POST transactions",
+		}},
+		{"rolled back", "transactions", true, []string{
+			"POST accounts", "POST categories", "POST transactions",
+			"DELETE categories id=in.(new-categories-0)",
+			"DELETE accounts id=in.(new-accounts-0,new-accounts-1)",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger.New(false)
+			var requests []string
+			var sentTxs []map[string]any
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				table := strings.TrimPrefix(r.URL.Path, "/rest/v1/")
+				if r.Method == http.MethodDelete {
+					requests = append(requests, "DELETE "+table+" id="+r.URL.Query().Get("id"))
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				requests = append(requests, r.Method+" "+table)
+				if table == tt.failOn {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"message":"failed"}`))
+					return
+				}
+
+				body, _ := io.ReadAll(r.Body)
+				var rows []map[string]any
+				if err := json.Unmarshal(body, &rows); err != nil {
+					t.Errorf("%s body is not an array: %s", table, body)
+				}
+				if table == "transactions" {
+					sentTxs = rows
+				}
+				for i := range rows {
+					rows[i]["id"] = fmt.Sprintf("new-%s-%d", table, i)
+				}
+				json.NewEncoder(w).Encode(rows)
+			}))
+			defer srv.Close()
+			prev := config.DB
+			config.DB = db.New(postgres.New(srv.URL + "/rest/v1"))
+			defer func() { config.DB = prev }()
+
+			err := restoreArchive(context.Background(), workspace{userID: "user-1"}, archive)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("restoreArchive error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := strings.Join(requests, "\n"); got != strings.Join(tt.requests, "\n") {
+				t.Errorf("requests:\n%s\nwant:\n%s", got, strings.Join(tt.requests, "\n"))
+			}
+			if tt.wantErr {
+				return
+			}
+
+			want := []map[string]any{
+				{"account_id": "new-accounts-1", "category_id": "new-categories-0", "description": ""},
+				{"account_id": "new-accounts-0", "category_id": nil, "description": "Salary"},
+			}
+			for i, w := range want {
+				for key, value := range w {
+					if got, ok := sentTxs[i][key]; !ok || got != value {
+						t.Errorf("transaction %d %s = %v, want %v", i, key, got, value)
+					}
+				}
+			}
+		})
+	}
+}
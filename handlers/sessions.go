@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/logging"
+	"github.com/leo140803/finance-app-backend/middleware"
+	"github.com/leo140803/finance-app-backend/models"
+	"github.com/lengzuo/supa/utils/enum"
+)
+
+type revokedAt struct {
+	RevokedAt string `json:"revoked_at"`
+}
+
+// revokeSession marks a single session as revoked, used by Logout where
+// the session's user is only known from the token.
+func revokeSession(ctx context.Context, sessionID string) {
+	err := config.DB.From("sessions").
+		Update(revokedAt{time.Now().UTC().Format(time.RFC3339)}).
+		Eq("id", sessionID).
+		Is("revoked_at", "null").
+		Execute(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to revoke session", "session_id", sessionID, "error", err)
+	}
+	middleware.ForgetSession(sessionID)
+}
+
+// revokeUserSessions marks every open session of the user except keep as
+// revoked and returns them. The rows are kept rather than deleted, so
+// tokens of these sessions are not recorded again as new sessions.
+func revokeUserSessions(ctx context.Context, userID, keep string) ([]models.Session, error) {
+	q := config.DB.From("sessions").
+		Update(revokedAt{time.Now().UTC().Format(time.RFC3339)}).
+		Eq("user_id", userID).
+		Is("revoked_at", "null")
+	if keep != "" {
+		q = q.Neq("id", keep)
+	}
+
+	var revoked []models.Session
+	if err := q.Execute(ctx, &revoked); err != nil {
+		return nil, err
+	}
+	for _, s := range revoked {
+		middleware.ForgetSession(s.ID)
+	}
+	return revoked, nil
+}
+
+// signOutEverywhere revokes every session of the user and ends them in
+// Supabase Auth too, so their refresh tokens stop working. Without the
+// service role key only the revocation here happens, which still rejects
+// the sessions' tokens at this API.
+func signOutEverywhere(ctx context.Context, userID, email string) error {
+	if _, err := revokeUserSessions(ctx, userID, ""); err != nil {
+		return fmt.Errorf("revoke sessions: %w", err)
+	}
+	if config.AuthAdmin == nil {
+		return nil
+	}
+	if err := config.AuthAdmin.SignOutUser(ctx, email); err != nil {
+		return fmt.Errorf("sign out in Supabase Auth: %w", err)
+	}
+	return nil
+}
+
+// signOutOtherSessions revokes every session of the user except the one
+// making the request and ends them in Supabase Auth too. Requests with a
+// personal access token have no session, so then every session ends;
+// userToken is an access token of the user to sign them out with.
+func signOutOtherSessions(c *gin.Context, userID, userToken string) error {
+	ctx := c.Request.Context()
+	current := c.GetString("session_id")
+	if _, err := revokeUserSessions(ctx, userID, current); err != nil {
+		return fmt.Errorf("revoke sessions: %w", err)
+	}
+	scope := "global"
+	if current != "" {
+		userToken, scope = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "), "others"
+	}
+	if err := config.SignOut(ctx, userToken, scope); err != nil {
+		return fmt.Errorf("sign out in Supabase Auth: %w", err)
+	}
+	return nil
+}
+
+func GetSessions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	var sessions []models.Session
+	err := config.DB.From("sessions").Select("*").
+		Order("last_seen_at", enum.OrderDesc).
+		Eq("user_id", userID.(string)).
+		Is("revoked_at", "null").
+		Execute(c.Request.Context(), &sessions)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch sessions: %w", err))
+		return
+	}
+
+	current := c.GetString("session_id")
+	response := make([]models.SessionResponse, len(sessions))
+	for i, s := range sessions {
+		response[i] = models.SessionResponse{Session: s, Current: s.ID == current}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func RevokeSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	sessionID := c.Param("id")
+
+	var revoked []models.Session
+	err := config.DB.From("sessions").
+		Update(revokedAt{time.Now().UTC().Format(time.RFC3339)}).
+		Eq("id", sessionID).
+		Eq("user_id", userID.(string)).
+		Is("revoked_at", "null").
+		Execute(c.Request.Context(), &revoked)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("revoke session: %w", err))
+		return
+	}
+
+	if len(revoked) == 0 {
+		apierror.Respond(c, apierror.ErrSessionNotFound)
+		return
+	}
+	middleware.ForgetSession(sessionID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// RevokeOtherSessions signs out every session of the user except the one
+// making the request.
+func RevokeOtherSessions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	revoked, err := revokeUserSessions(c.Request.Context(), userID.(string), c.GetString("session_id"))
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("revoke sessions: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Other sessions revoked successfully", "revoked": len(revoked)})
+}
@@ -2,32 +2,49 @@ package handlers
 
 import (
     "context"
+    "fmt"
     "net/http"
-	"log"
+	"time"
 
     "github.com/gin-gonic/gin"
+    "github.com/leo140803/finance-app-backend/apierror"
     "github.com/leo140803/finance-app-backend/config"
+    "github.com/leo140803/finance-app-backend/metrics"
+    "github.com/leo140803/finance-app-backend/middleware"
     "github.com/leo140803/finance-app-backend/models"
     "github.com/lengzuo/supa/utils/enum"
 )
 
 func GetTransactions(c *gin.Context) {
-	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	// Personal transactions, or the household's (set by middleware)
+	ws, ok := currentWorkspace(c, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	var filter models.TransactionFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+
+	// Personal transactions include those on accounts other users shared
+	// with this one
+	scope, err := transactionScope(c.Request.Context(), ws)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch transactions: %w", err))
 		return
 	}
 
 	var transactions []models.Transaction
 
-	// bisa ditambah filter pakai query param
-	err := config.SupaClient.DB.From("transactions").Select("*").
-		Order("date", enum.OrderAsc).
-		Eq("user_id", userID.(string)).Execute(context.Background(), &transactions)
+	q := config.DB.From("transactions").Select("*").
+		Order("date", enum.OrderAsc)
+	scope(&q.FilterBuilder)
+	err = applyTransactionFilter(q, filter).Execute(c.Request.Context(), &transactions)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
+		apierror.Respond(c, fmt.Errorf("fetch transactions: %w", err))
 		return
 	}
 
@@ -35,41 +52,60 @@ func GetTransactions(c *gin.Context) {
 }
 
 func CreateTransaction(c *gin.Context) {
-	// Get user ID dari context
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	ws, ok := currentWorkspace(c, models.RoleEditor)
+	if !ok {
 		return
 	}
 
 	var tx models.Transaction
 	if err := c.ShouldBindJSON(&tx); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 
-	// Inject user id
-	tx.UserID = userID.(string)
 	tx.ID = ""
 	tx.CreatedAt = ""
 
-	// 1️⃣ Ambil saldo terkini dari tabel accounts
-	var accounts []models.Account
-	err := config.SupaClient.DB.From("accounts").
-		Select("*").
-		Eq("id", tx.AccountID).
-		Execute(context.Background(), &accounts)
+	// Transactions without a date happen "today" in the user's time zone
+	if tx.Date == "" {
+		prefs, err := userPreferences(c.Request.Context(), ws.userID)
+		if err != nil {
+			apierror.Respond(c, fmt.Errorf("fetch profile: %w", err))
+			return
+		}
+		tx.Date = time.Now().In(prefs.loc).Format(dateLayout)
+	}
 
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch account: " + err.Error()})
+	// Without an account the transaction goes to the default account from
+	// the profile, which is always a personal one
+	if tx.AccountID == "" && ws.householdID == "" {
+		var users []models.User
+		err := config.DB.From("users").Select("default_account_id").Eq("id", ws.userID).Execute(c.Request.Context(), &users)
+		if err != nil {
+			apierror.Respond(c, fmt.Errorf("fetch profile: %w", err))
+			return
+		}
+		if len(users) > 0 {
+			tx.AccountID = users[0].DefaultAccountID
+		}
+	}
+	if tx.AccountID == "" {
+		apierror.Respond(c, apierror.ErrValidation.WithFields(apierror.FieldError{Field: "account_id", Rule: "required"}))
 		return
 	}
-	if len(accounts) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+
+	// 1️⃣ Ambil saldo terkini dari tabel accounts
+	account, ok := transactionAccount(c, ws, tx)
+	if !ok {
 		return
 	}
 
-	lastBalance := accounts[0].InitialBalance
+	// Inject user id: the transaction belongs to the account's owner, also
+	// when it is recorded by someone the account is shared with
+	tx.UserID = account.UserID
+	tx.HouseholdID = account.HouseholdID
+
+	lastBalance := account.InitialBalance
 
 	// 2️⃣ Hitung saldo baru & isi balance_after di transaksi
 	newBalance := 0.0
@@ -79,29 +115,32 @@ func CreateTransaction(c *gin.Context) {
 		newBalance = lastBalance - tx.Amount
 	}
 	tx.BalanceAfter = newBalance
-	log.Printf("💰 CreateTransaction: account=%s lastBalance=%.2f amount=%.2f type=%s newBalance=%.2f",
-	tx.AccountID, lastBalance, tx.Amount, tx.Type, newBalance)
+	middleware.Log(c).Debug("creating transaction",
+		"account_id", tx.AccountID, "last_balance", lastBalance, "amount", tx.Amount, "type", tx.Type, "new_balance", newBalance)
 
 	// 3️⃣ Insert transaksi baru
 	var result interface{}
-	err = config.SupaClient.DB.From("transactions").
+	err := config.DB.From("transactions").
 		Insert(tx).
-		Execute(context.Background(), &result)
+		Execute(c.Request.Context(), &result)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to insert transaction"})
+		apierror.Respond(c, fmt.Errorf("insert transaction: %w", err))
 		return
 	}
+	metrics.TransactionsCreated.Inc()
 
-	// 4️⃣ Update saldo di tabel accounts
+	// 4️⃣ Update saldo di tabel accounts. The transaction is already stored,
+	// so the balance is updated even if the client has gone away; the
+	// storage timeout still applies.
 	updateData := models.UpdateAccountBalance{InitialBalance: newBalance}
-	err = config.SupaClient.DB.
+	err = config.DB.
 		From("accounts").
 		Update(updateData).
 		Eq("id", tx.AccountID).
-		Execute(context.Background(), nil)
+		Execute(context.WithoutCancel(c.Request.Context()), nil)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update account balance: " + err.Error()})
+		apierror.Respond(c, fmt.Errorf("update account balance: %w", err))
 		return
 	}
 
@@ -116,54 +155,109 @@ func CreateTransaction(c *gin.Context) {
 	})
 }
 
+// transactionAccount loads the account tx is recorded on and checks that
+// the workspace may write to it and that tx's category belongs to the
+// account's workspace. Otherwise it writes the error response and returns
+// false.
+func transactionAccount(c *gin.Context, ws workspace, tx models.Transaction) (*models.Account, bool) {
+	ctx := c.Request.Context()
+
+	account, access, err := accessibleAccount(ctx, ws, tx.AccountID)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch account: %w", err))
+		return nil, false
+	}
+	if account == nil {
+		apierror.Respond(c, apierror.ErrValidation.WithFields(apierror.FieldError{Field: "account_id", Rule: "exists"}))
+		return nil, false
+	}
+	if access != models.AccessWrite {
+		apierror.Respond(c, apierror.ErrReadOnlyAccount)
+		return nil, false
+	}
+
+	if tx.CategoryID != "" {
+		// Categories are shared with the account: a household account takes
+		// household categories, a personal one its owner's categories
+		var categories []models.Category
+		q := config.DB.From("categories").Select("id")
+		q.Eq("id", tx.CategoryID)
+		owner := workspace{userID: account.UserID, householdID: account.HouseholdID}
+		if err := owner.scope(&q.FilterBuilder).Execute(ctx, &categories); err != nil {
+			apierror.Respond(c, fmt.Errorf("fetch category: %w", err))
+			return nil, false
+		}
+		if len(categories) == 0 {
+			apierror.Respond(c, apierror.ErrValidation.WithFields(apierror.FieldError{Field: "category_id", Rule: "exists"}))
+			return nil, false
+		}
+	}
+	return account, true
+}
+
 func UpdateTransaction(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	ws, ok := currentWorkspace(c, models.RoleEditor)
+	if !ok {
 		return
 	}
 
 	txID := c.Param("id")
 
 	// Ambil transaksi lama
-	var oldTx []models.Transaction
-	err := config.SupaClient.DB.From("transactions").
-		Select("*").
-		Eq("id", txID).
-		Eq("user_id", userID.(string)).
-		Execute(context.Background(), &oldTx)
+	oldTx, access, err := accessibleTransaction(c.Request.Context(), ws, txID)
 
-	if err != nil || len(oldTx) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch transaction: %w", err))
+		return
+	}
+	if oldTx == nil {
+		apierror.Respond(c, apierror.ErrTransactionNotFound)
+		return
+	}
+	if access != models.AccessWrite {
+		apierror.Respond(c, apierror.ErrReadOnlyAccount)
 		return
 	}
-	prevTx := oldTx[0]
+	prevTx := *oldTx
 
 	// Bind data baru
 	var newTx models.Transaction
 	if err := c.ShouldBindJSON(&newTx); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		apierror.Respond(c, apierror.Invalid(err))
 		return
 	}
 	newTx.ID = txID
-	newTx.UserID = userID.(string)
+	newTx.CreatedAt = ""
+	if newTx.Date == "" {
+		newTx.Date = prevTx.Date
+	}
+	if newTx.AccountID == "" {
+		newTx.AccountID = prevTx.AccountID
+	}
+
+	// Load and check both accounts, and the new category, before touching
+	// any balance
+	newAcc, ok := transactionAccount(c, ws, newTx)
+	if !ok {
+		return
+	}
 
-	ctx := context.Background()
+	ctx := c.Request.Context()
+
+	oldAcc, _, err := accessibleAccount(ctx, ws, prevTx.AccountID)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch account: %w", err))
+		return
+	}
+	if oldAcc == nil {
+		apierror.Respond(c, apierror.ErrAccountNotFound)
+		return
+	}
 
 	// -----------------------------
 	// Step 1: Revert saldo account lama
 	// -----------------------------
-	var oldAcc []models.Account
-	err = config.SupaClient.DB.From("accounts").
-		Select("*").
-		Eq("id", prevTx.AccountID).
-		Eq("user_id", userID.(string)).
-		Execute(ctx, &oldAcc)
-	if err != nil || len(oldAcc) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Old account not found"})
-		return
-	}
-	accountOld := oldAcc[0]
+	accountOld := *oldAcc
 
 	if prevTx.Type == "INCOME" {
 		accountOld.InitialBalance -= prevTx.Amount
@@ -171,30 +265,31 @@ func UpdateTransaction(c *gin.Context) {
 		accountOld.InitialBalance += prevTx.Amount
 	}
 
-	// Update saldo account lama
-	err = config.SupaClient.DB.From("accounts").
-		Update(models.UpdateAccountBalance{InitialBalance: accountOld.InitialBalance}).
-		Eq("id", prevTx.AccountID).
-		Execute(ctx, nil)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update old account balance"})
-		return
-	}
-
 	// -----------------------------
 	// Step 2: Apply transaksi baru ke account baru
 	// -----------------------------
-	var newAcc []models.Account
-	err = config.SupaClient.DB.From("accounts").
-		Select("*").
-		Eq("id", newTx.AccountID).
-		Eq("user_id", userID.(string)).
-		Execute(ctx, &newAcc)
-	if err != nil || len(newAcc) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "New account not found"})
-		return
+	accountNew := *newAcc
+	if newTx.AccountID == prevTx.AccountID {
+		// Same account: apply the new amount to the reverted balance and
+		// write it once
+		accountNew = accountOld
+	} else {
+		// Update saldo account lama
+		err = config.DB.From("accounts").
+			Update(models.UpdateAccountBalance{InitialBalance: accountOld.InitialBalance}).
+			Eq("id", prevTx.AccountID).
+			Execute(ctx, nil)
+		if err != nil {
+			apierror.Respond(c, fmt.Errorf("update old account balance: %w", err))
+			return
+		}
 	}
-	accountNew := newAcc[0]
+	// From here on the balances may be half updated: finish the remaining
+	// steps even if the client goes away
+	ctx = context.WithoutCancel(ctx)
+
+	newTx.UserID = accountNew.UserID
+	newTx.HouseholdID = accountNew.HouseholdID
 
 	if newTx.Type == "INCOME" {
 		accountNew.InitialBalance += newTx.Amount
@@ -205,22 +300,22 @@ func UpdateTransaction(c *gin.Context) {
 
 	// Update transaksi
 	var result interface{}
-	err = config.SupaClient.DB.From("transactions").
+	err = config.DB.From("transactions").
 		Update(newTx).
 		Eq("id", txID).
 		Execute(ctx, &result)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 
 	// Update saldo account baru
-	err = config.SupaClient.DB.From("accounts").
+	err = config.DB.From("accounts").
 		Update(models.UpdateAccountBalance{InitialBalance: accountNew.InitialBalance}).
 		Eq("id", newTx.AccountID).
 		Execute(ctx, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update new account balance"})
+		apierror.Respond(c, fmt.Errorf("update new account balance: %w", err))
 		return
 	}
 
@@ -229,37 +324,43 @@ func UpdateTransaction(c *gin.Context) {
 
 
 func DeleteTransaction(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	ws, ok := currentWorkspace(c, models.RoleEditor)
+	if !ok {
 		return
 	}
 
 	txID := c.Param("id")
 
 	// Ambil transaksi lama
-	var oldTx []models.Transaction
-	err := config.SupaClient.DB.From("transactions").
-		Select("*").
-		Eq("id", txID).
-		Eq("user_id", userID.(string)).
-		Execute(context.Background(), &oldTx)
+	oldTx, access, err := accessibleTransaction(c.Request.Context(), ws, txID)
 
-	if err != nil || len(oldTx) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Transaction not found"})
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch transaction: %w", err))
 		return
 	}
-	tx := oldTx[0]
+	if oldTx == nil {
+		apierror.Respond(c, apierror.ErrTransactionNotFound)
+		return
+	}
+	if access != models.AccessWrite {
+		apierror.Respond(c, apierror.ErrReadOnlyAccount)
+		return
+	}
+	tx := *oldTx
 
 	// Ambil account
 	var accounts []models.Account
-	err = config.SupaClient.DB.From("accounts").
+	err = config.DB.From("accounts").
 		Select("*").
 		Eq("id", tx.AccountID).
-		Execute(context.Background(), &accounts)
+		Execute(c.Request.Context(), &accounts)
 
-	if err != nil || len(accounts) == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Account not found"})
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch account: %w", err))
+		return
+	}
+	if len(accounts) == 0 {
+		apierror.Respond(c, apierror.ErrAccountNotFound)
 		return
 	}
 	account := accounts[0]
@@ -274,25 +375,25 @@ func DeleteTransaction(c *gin.Context) {
 
 	// Hapus transaksi
 	var result interface{}
-	err = config.SupaClient.DB.From("transactions").
+	err = config.DB.From("transactions").
 		Delete().
 		Eq("id", txID).
-		Execute(context.Background(), &result)
+		Execute(c.Request.Context(), &result)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete transaction"})
+		apierror.Respond(c, fmt.Errorf("delete transaction: %w", err))
 		return
 	}
 
-	// Update saldo account
+	// Update saldo account, also if the client has gone away after the delete
 	updateData := models.UpdateAccountBalance{InitialBalance: lastBalance}
-	err = config.SupaClient.DB.From("accounts").
+	err = config.DB.From("accounts").
 		Update(updateData).
 		Eq("id", tx.AccountID).
-		Execute(context.Background(), nil)
+		Execute(context.WithoutCancel(c.Request.Context()), nil)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update account balance"})
+		apierror.Respond(c, fmt.Errorf("update account balance: %w", err))
 		return
 	}
 
@@ -0,0 +1,507 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/metrics"
+	"github.com/leo140803/finance-app-backend/middleware"
+	"github.com/leo140803/finance-app-backend/models"
+	"github.com/leo140803/finance-app-backend/totp"
+)
+
+const (
+	totpIssuer         = "Duitku"
+	recoveryCodeCount  = 10
+	challengeTTL       = 5 * time.Minute
+	maxChallengeTries  = 5
+	challengeTokenSize = 32
+)
+
+var errTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+
+// getTwoFactor returns the user's 2FA settings with the secret decrypted.
+// Secrets stored before they were encrypted are encrypted on first use.
+func getTwoFactor(ctx context.Context, userID string) (*models.TwoFactor, error) {
+	var rows []models.TwoFactor
+	err := config.DB.From("two_factor").Select("*").Eq("user_id", userID).Execute(ctx, &rows)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	tf := &rows[0]
+	stored := tf.Secret
+	if tf.Secret, err = config.Decrypt(stored); err != nil {
+		return nil, fmt.Errorf("decrypt 2FA secret: %w", err)
+	}
+	if !config.Encrypted(stored) {
+		encrypted, err := config.Encrypt(tf.Secret)
+		if err != nil {
+			return nil, err
+		}
+		err = config.DB.From("two_factor").
+			Update(map[string]string{"secret": encrypted}).
+			Eq("user_id", userID).
+			Execute(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tf, nil
+}
+
+// newRecoveryCodes returns fresh recovery codes and their hashes.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = middleware.HashToken(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused
+// recovery code. TOTP codes cannot be replayed and recovery codes are
+// consumed on use.
+func verifySecondFactor(ctx context.Context, tf *models.TwoFactor, code string) (bool, error) {
+	if step, ok := totp.Validate(tf.Secret, code, time.Now()); ok {
+		if step <= tf.LastUsedStep {
+			return false, nil
+		}
+		// Only one of two requests with the same code can move the step on
+		var updated []models.TwoFactor
+		err := config.DB.From("two_factor").
+			Update(map[string]int64{"last_used_step": step}).
+			Eq("user_id", tf.UserID).
+			Lt("last_used_step", strconv.FormatInt(step, 10)).
+			Execute(ctx, &updated)
+		if err != nil {
+			return false, err
+		}
+		tf.LastUsedStep = step
+		return len(updated) > 0, nil
+	}
+
+	hash := middleware.HashToken(strings.ToLower(strings.TrimSpace(code)))
+	i := slices.Index(tf.RecoveryCodes, hash)
+	if i < 0 {
+		return false, nil
+	}
+	tf.RecoveryCodes = slices.Delete(tf.RecoveryCodes, i, i+1)
+	err := config.DB.From("two_factor").
+		Update(map[string][]string{"recovery_codes": tf.RecoveryCodes}).
+		Eq("user_id", tf.UserID).
+		Execute(ctx, nil)
+	return err == nil, err
+}
+
+// SetupTwoFactor starts enrollment with a new secret. 2FA is only switched
+// on once EnableTwoFactor has seen a valid code for it.
+func SetupTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+	ctx := c.Request.Context()
+
+	existing, err := getTwoFactor(ctx, userID.(string))
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch 2FA settings: %w", err))
+		return
+	}
+	if existing != nil && existing.Enabled {
+		apierror.Respond(c, apierror.ErrTwoFactorEnabled)
+		return
+	}
+
+	var users []models.User
+	err = config.DB.From("users").Select("*").Eq("id", userID.(string)).Execute(ctx, &users)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch user: %w", err))
+		return
+	}
+	if len(users) == 0 {
+		apierror.Respond(c, apierror.ErrUserNotFound)
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("generate secret: %w", err))
+		return
+	}
+
+	encrypted, err := config.Encrypt(secret)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("encrypt secret: %w", err))
+		return
+	}
+
+	var result interface{}
+	err = config.DB.From("two_factor").Upsert(models.TwoFactor{
+		UserID:        userID.(string),
+		Secret:        encrypted,
+		RecoveryCodes: []string{},
+	}).Execute(ctx, &result)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("save 2FA settings: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(secret, totpIssuer, users[0].Email),
+	})
+}
+
+// EnableTwoFactor confirms enrollment with a code from the authenticator
+// app and returns the recovery codes, which are only shown this once. The
+// session making the request has just passed the second factor; the
+// user's other sessions stop working.
+func EnableTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	ctx := c.Request.Context()
+
+	tf, err := getTwoFactor(ctx, userID.(string))
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch 2FA settings: %w", err))
+		return
+	}
+	if tf == nil {
+		apierror.Respond(c, apierror.ErrTwoFactorSetupMissing)
+		return
+	}
+	if tf.Enabled {
+		apierror.Respond(c, apierror.ErrTwoFactorEnabled)
+		return
+	}
+
+	step, ok := totp.Validate(tf.Secret, req.Code, time.Now())
+	if !ok {
+		apierror.Respond(c, apierror.ErrInvalidCode)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("generate recovery codes: %w", err))
+		return
+	}
+
+	err = config.DB.From("two_factor").Update(map[string]interface{}{
+		"enabled":        true,
+		"last_used_step": step,
+		"recovery_codes": hashes,
+	}).Eq("user_id", tf.UserID).Execute(ctx, nil)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("enable 2FA: %w", err))
+		return
+	}
+
+	if id := c.GetString("session_id"); id != "" {
+		if err := middleware.PassSecondFactor(ctx, id); err != nil {
+			apierror.Respond(c, fmt.Errorf("update session: %w", err))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTwoFactor turns 2FA off after checking a code or recovery code.
+func DisableTwoFactor(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	ctx := c.Request.Context()
+
+	tf := checkTwoFactorCode(c, userID.(string), req.Code)
+	if tf == nil {
+		return
+	}
+
+	err := config.DB.From("two_factor").Delete().Eq("user_id", tf.UserID).Execute(ctx, nil)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("disable 2FA: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a
+// code or recovery code.
+func RegenerateRecoveryCodes(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	ctx := c.Request.Context()
+
+	tf := checkTwoFactorCode(c, userID.(string), req.Code)
+	if tf == nil {
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("generate recovery codes: %w", err))
+		return
+	}
+	err = config.DB.From("two_factor").
+		Update(map[string][]string{"recovery_codes": hashes}).
+		Eq("user_id", tf.UserID).
+		Execute(ctx, nil)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("save recovery codes: %w", err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+var errInvalidCode = errors.New("invalid code")
+
+// requireTwoFactorCode loads the user's enabled 2FA settings and checks
+// code against them.
+func requireTwoFactorCode(ctx context.Context, userID, code string) (*models.TwoFactor, error) {
+	tf, err := getTwoFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil || !tf.Enabled {
+		return nil, errTwoFactorNotEnabled
+	}
+	ok, err := verifySecondFactor(ctx, tf, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errInvalidCode
+	}
+	return tf, nil
+}
+
+// twoFactorLockKey is the config.LoginLockout key counting a user's wrong
+// 2FA codes.
+func twoFactorLockKey(userID string) string {
+	return "2fa:" + userID
+}
+
+// checkTwoFactorCode is requireTwoFactorCode behind the same per-user
+// lockout as LoginTwoFactor. Otherwise it writes the error response and
+// returns nil.
+func checkTwoFactorCode(c *gin.Context, userID, code string) *models.TwoFactor {
+	ctx := c.Request.Context()
+	lockKey := twoFactorLockKey(userID)
+	if retryAfter, err := config.LoginLockout.Locked(ctx, lockKey); err == nil && retryAfter > 0 {
+		middleware.TooManyRequests(c, retryAfter, apierror.ErrTooManyInvalidCodes)
+		return nil
+	}
+
+	tf, err := requireTwoFactorCode(ctx, userID, code)
+	if errors.Is(err, errInvalidCode) {
+		metrics.AuthFailures.WithLabelValues("invalid_2fa_code").Inc()
+		if lockedFor, _ := config.LoginLockout.Fail(ctx, lockKey); lockedFor > 0 {
+			middleware.TooManyRequests(c, lockedFor, apierror.ErrTooManyInvalidCodes)
+			return nil
+		}
+	}
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return nil
+	}
+	config.LoginLockout.Succeed(ctx, lockKey)
+	return tf
+}
+
+func respondTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errTwoFactorNotEnabled):
+		apierror.Respond(c, apierror.ErrTwoFactorNotEnabled)
+	case errors.Is(err, errInvalidCode):
+		apierror.Respond(c, apierror.ErrInvalidCode)
+	default:
+		apierror.Respond(c, fmt.Errorf("verify code: %w", err))
+	}
+}
+
+// startLoginChallenge parks the tokens of a password login until the user
+// has passed the second factor and returns the challenge for the client.
+func startLoginChallenge(ctx context.Context, userID, accessToken, refreshToken string) (*models.TwoFactorRequiredResponse, error) {
+	b := make([]byte, challengeTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	token := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+	expires := time.Now().UTC().Add(challengeTTL).Format(time.RFC3339)
+
+	encryptedAccess, err := config.Encrypt(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	encryptedRefresh, err := config.Encrypt(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.DB.From("login_challenges").Insert(models.LoginChallenge{
+		TokenHash:    middleware.HashToken(token),
+		UserID:       userID,
+		AccessToken:  encryptedAccess,
+		RefreshToken: encryptedRefresh,
+		ExpiresAt:    expires,
+	}).Execute(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorRequiredResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         expires,
+	}, nil
+}
+
+// LoginTwoFactor is the second step of Login for users with 2FA: it
+// exchanges the challenge token and a valid code for the AuthResponse.
+func LoginTwoFactor(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.Respond(c, apierror.Invalid(err))
+		return
+	}
+	ctx := c.Request.Context()
+
+	var challenges []models.LoginChallenge
+	err := config.DB.From("login_challenges").Select("*").
+		Eq("token_hash", middleware.HashToken(req.ChallengeToken)).
+		Execute(ctx, &challenges)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch login challenge: %w", err))
+		return
+	}
+	if len(challenges) == 0 {
+		metrics.AuthFailures.WithLabelValues("invalid_challenge").Inc()
+		apierror.Respond(c, apierror.ErrInvalidChallenge)
+		return
+	}
+	challenge := challenges[0]
+	deleteChallenge := func() {
+		config.DB.From("login_challenges").Delete().Eq("id", challenge.ID).Execute(ctx, nil)
+	}
+
+	expires, err := time.Parse(time.RFC3339, challenge.ExpiresAt)
+	if err != nil || time.Now().After(expires) || challenge.Attempts >= maxChallengeTries {
+		deleteChallenge()
+		metrics.AuthFailures.WithLabelValues("invalid_challenge").Inc()
+		apierror.Respond(c, apierror.ErrInvalidChallenge)
+		return
+	}
+
+	// Wrong codes also count per user, so fresh challenges from new
+	// password logins cannot be used to keep guessing.
+	lockKey := twoFactorLockKey(challenge.UserID)
+	if retryAfter, err := config.LoginLockout.Locked(ctx, lockKey); err == nil && retryAfter > 0 {
+		middleware.TooManyRequests(c, retryAfter, apierror.ErrTooManyInvalidCodes)
+		return
+	}
+
+	_, err = requireTwoFactorCode(ctx, challenge.UserID, req.Code)
+	if errors.Is(err, errInvalidCode) {
+		config.DB.From("login_challenges").
+			Update(map[string]int{"attempts": challenge.Attempts + 1}).
+			Eq("id", challenge.ID).
+			Execute(ctx, nil)
+		metrics.AuthFailures.WithLabelValues("invalid_2fa_code").Inc()
+		if lockedFor, _ := config.LoginLockout.Fail(ctx, lockKey); lockedFor > 0 {
+			middleware.TooManyRequests(c, lockedFor, apierror.ErrTooManyInvalidCodes)
+			return
+		}
+		apierror.Respond(c, apierror.ErrInvalidCode)
+		return
+	}
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+	deleteChallenge()
+	config.LoginLockout.Succeed(ctx, lockKey)
+
+	accessToken, err := config.Decrypt(challenge.AccessToken)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("decrypt login challenge: %w", err))
+		return
+	}
+	refreshToken, err := config.Decrypt(challenge.RefreshToken)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("decrypt login challenge: %w", err))
+		return
+	}
+
+	var users []models.User
+	err = config.DB.From("users").Select("*").Eq("id", challenge.UserID).Execute(ctx, &users)
+	if err != nil {
+		apierror.Respond(c, fmt.Errorf("fetch user: %w", err))
+		return
+	}
+	if len(users) == 0 {
+		apierror.Respond(c, apierror.ErrUserNotFound)
+		return
+	}
+
+	if err := middleware.StartSession(c, users[0].ID, accessToken, true); err != nil {
+		apierror.Respond(c, fmt.Errorf("record session: %w", err))
+		return
+	}
+
+	response := models.AuthResponse{
+		User:         users[0],
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
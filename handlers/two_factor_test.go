@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leo140803/finance-app-backend/middleware"
+	"github.com/leo140803/finance-app-backend/models"
+	"github.com/leo140803/finance-app-backend/totp"
+)
+
+const testSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestVerifySecondFactorTOTP(t *testing.T) {
+	step := totp.Step(time.Now())
+	code, err := totp.Code(testSecret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		lastUsed int64
+		rows     string // rows the conditional update changed
+		want     bool
+	}{
+		{"fresh code", step - 1, `[{"user_id":"user-1"}]`, true},
+		{"already used", step, `[{"user_id":"user-1"}]`, false},
+		{"used by a concurrent request", step - 1, `[]`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakePostgREST(t, tt.rows)
+			tf := &models.TwoFactor{UserID: "user-1", Secret: testSecret, LastUsedStep: tt.lastUsed}
+
+			ok, err := verifySecondFactor(context.Background(), tf, code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.want {
+				t.Errorf("verifySecondFactor = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifySecondFactorRecoveryCode(t *testing.T) {
+	fakePostgREST(t, `[]`)
+	tf := &models.TwoFactor{
+		UserID:        "user-1",
+		Secret:        testSecret,
+		RecoveryCodes: []string{middleware.HashToken("abcd-efgh"), middleware.HashToken("ijkl-mnop")},
+	}
+
+	tests := []struct {
+		name string
+		code string
+		want bool
+	}{
+		{"unused", " ABCD-EFGH ", true},
+		{"reused", "abcd-efgh", false},
+		{"unknown", "qrst-uvwx", false},
+		{"other code still works", "ijkl-mnop", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := verifySecondFactor(context.Background(), tf, tt.code)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.want {
+				t.Errorf("verifySecondFactor(%q) = %v, want %v", tt.code, ok, tt.want)
+			}
+		})
+	}
+	if len(tf.RecoveryCodes) != 0 {
+		t.Errorf("%d recovery codes left, want 0", len(tf.RecoveryCodes))
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/db"
+	"github.com/leo140803/finance-app-backend/models"
+)
+
+var roleRank = map[string]int{
+	models.RoleViewer: 1,
+	models.RoleEditor: 2,
+	models.RoleOwner:  3,
+}
+
+// workspace is the data a request works on: the user's personal data, or
+// the household selected by middleware.Workspace.
+type workspace struct {
+	userID      string
+	householdID string
+	role        string
+}
+
+// currentWorkspace returns the request's workspace if the user's role in
+// it is at least minRole. Users own their personal data. Otherwise it
+// writes the error response and returns false.
+func currentWorkspace(c *gin.Context, minRole string) (workspace, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		apierror.Respond(c, apierror.ErrUnauthenticated)
+		return workspace{}, false
+	}
+
+	ws := workspace{userID: userID.(string), role: models.RoleOwner}
+	if householdID := c.GetString("household_id"); householdID != "" {
+		ws.householdID = householdID
+		ws.role = c.GetString("household_role")
+	}
+
+	if roleRank[ws.role] < roleRank[minRole] {
+		apierror.Respond(c, apierror.ErrRoleForbidden)
+		return ws, false
+	}
+	return ws, true
+}
+
+// scope restricts q to the rows owned by the workspace.
+func (w workspace) scope(q *db.FilterBuilder) *db.FilterBuilder {
+	if w.householdID != "" {
+		return q.Eq("household_id", w.householdID)
+	}
+	return q.Eq("user_id", w.userID).Is("household_id", "null")
+}
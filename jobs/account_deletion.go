@@ -0,0 +1,192 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/middleware"
+	"github.com/leo140803/finance-app-backend/models"
+)
+
+// dataTables hold rows that belong either to a user or to a household, in
+// an order that respects foreign keys.
+var dataTables = []string{"transactions", "accounts", "categories"}
+
+// deletedUserID replaces the user_id of rows an erased user created in a
+// household, which stay with the household.
+const deletedUserID = "deleted-user"
+
+// userTables lists the other tables holding rows that belong to a user.
+var userTables = []string{
+	"account_shares",
+	"api_tokens",
+	"two_factor",
+	"login_challenges",
+	"used_refresh_tokens",
+	"sessions",
+}
+
+// AccountDeletion erases users whose deletion grace period has passed.
+func AccountDeletion() Job {
+	return Job{
+		Name:     "account-deletion",
+		Interval: time.Hour,
+		Run:      eraseDueAccounts,
+	}
+}
+
+func eraseDueAccounts(ctx context.Context) error {
+	if config.AuthAdmin == nil {
+		return nil
+	}
+
+	var due []models.AccountDeletion
+	err := config.DB.From("account_deletions").Select("*").
+		Lte("scheduled_for", time.Now().UTC().Format(time.RFC3339)).
+		Execute(ctx, &due)
+	if err != nil {
+		return fmt.Errorf("fetch due deletions: %w", err)
+	}
+
+	for _, d := range due {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := eraseUser(ctx, d); err != nil {
+			// Left in place, so the next run tries again
+			slog.Error("account deletion failed", "job", "account-deletion", "user_id", d.UserID, "error", err)
+			continue
+		}
+		slog.Info("account erased", "job", "account-deletion", "user_id", d.UserID)
+	}
+	return nil
+}
+
+// eraseUser removes the users row first so the account stops resolving,
+// then its data, the Supabase Auth user and finally the deletion request.
+// Every step can be repeated safely if a previous run stopped half way.
+func eraseUser(ctx context.Context, d models.AccountDeletion) error {
+	if err := config.DB.From("users").Delete().Eq("id", d.UserID).Execute(ctx, nil); err != nil {
+		return fmt.Errorf("delete users row: %w", err)
+	}
+	middleware.ForgetUser(d.Email)
+
+	// Household data stays with the household but no longer points at
+	// the user; personal rows go
+	for _, table := range dataTables {
+		if err := config.DB.From(table).Delete().Eq("user_id", d.UserID).Is("household_id", "null").Execute(ctx, nil); err != nil {
+			return fmt.Errorf("delete %s: %w", table, err)
+		}
+		err := config.DB.From(table).
+			Update(map[string]string{"user_id": deletedUserID}).
+			Eq("user_id", d.UserID).
+			Execute(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("anonymize %s: %w", table, err)
+		}
+	}
+	for _, table := range userTables {
+		if err := config.DB.From(table).Delete().Eq("user_id", d.UserID).Execute(ctx, nil); err != nil {
+			return fmt.Errorf("delete %s: %w", table, err)
+		}
+	}
+	if err := leaveHouseholds(ctx, d.UserID); err != nil {
+		return err
+	}
+	if err := forgetInvitations(ctx, d); err != nil {
+		return err
+	}
+	if err := forgetRateLimits(ctx, d); err != nil {
+		return err
+	}
+
+	if d.AuthUserID != "" {
+		if err := config.AuthAdmin.DeleteUser(ctx, d.AuthUserID); err != nil {
+			return fmt.Errorf("delete auth user: %w", err)
+		}
+	}
+
+	return config.DB.From("account_deletions").Delete().Eq("user_id", d.UserID).Execute(ctx, nil)
+}
+
+// forgetInvitations deletes the invitations sent to the user and removes
+// the user from those they sent, which stay valid.
+func forgetInvitations(ctx context.Context, d models.AccountDeletion) error {
+	if d.Email != "" {
+		err := config.DB.From("household_invitations").Delete().Eq("email", strings.ToLower(d.Email)).Execute(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("delete invitations: %w", err)
+		}
+	}
+	err := config.DB.From("household_invitations").
+		Update(map[string]string{"invited_by": deletedUserID}).
+		Eq("invited_by", d.UserID).
+		Execute(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("anonymize invitations: %w", err)
+	}
+	return nil
+}
+
+// forgetRateLimits drops the rate limit and lockout counters kept for the
+// user's email address and ID.
+func forgetRateLimits(ctx context.Context, d models.AccountDeletion) error {
+	if err := config.LoginLockout.Clear(ctx, "2fa:"+d.UserID); err != nil {
+		return fmt.Errorf("clear 2FA lockout: %w", err)
+	}
+	if d.Email == "" {
+		return nil
+	}
+	if err := config.LoginLockout.Clear(ctx, middleware.LoginLockKey(d.Email)); err != nil {
+		return fmt.Errorf("clear login lockout: %w", err)
+	}
+	for _, name := range []string{middleware.RegisterLimit, middleware.ForgotPasswordLimit} {
+		if err := config.RateLimitStore.Reset(ctx, middleware.EmailLimitKey(name, d.Email)); err != nil {
+			return fmt.Errorf("clear %s limit: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// leaveHouseholds removes the user from their households. Households left
+// without members are deleted together with their data.
+func leaveHouseholds(ctx context.Context, userID string) error {
+	var memberships []models.HouseholdMember
+	err := config.DB.From("household_members").Select("*").Eq("user_id", userID).Execute(ctx, &memberships)
+	if err != nil {
+		return fmt.Errorf("fetch households: %w", err)
+	}
+
+	for _, m := range memberships {
+		err := config.DB.From("household_members").Delete().
+			Eq("household_id", m.HouseholdID).
+			Eq("user_id", userID).
+			Execute(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("leave household %s: %w", m.HouseholdID, err)
+		}
+
+		var remaining []models.HouseholdMember
+		err = config.DB.From("household_members").Select("user_id").Eq("household_id", m.HouseholdID).Execute(ctx, &remaining)
+		if err != nil {
+			return fmt.Errorf("fetch members of household %s: %w", m.HouseholdID, err)
+		}
+		if len(remaining) > 0 {
+			continue
+		}
+
+		for _, table := range append(dataTables, "household_invitations") {
+			if err := config.DB.From(table).Delete().Eq("household_id", m.HouseholdID).Execute(ctx, nil); err != nil {
+				return fmt.Errorf("delete %s of household %s: %w", table, m.HouseholdID, err)
+			}
+		}
+		if err := config.DB.From("households").Delete().Eq("id", m.HouseholdID).Execute(ctx, nil); err != nil {
+			return fmt.Errorf("delete household %s: %w", m.HouseholdID, err)
+		}
+	}
+	return nil
+}
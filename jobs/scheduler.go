@@ -0,0 +1,126 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/leo140803/finance-app-backend/tracing"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Job is a task the scheduler runs on a fixed interval.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// JobStatus is the outcome of a job's latest run.
+type JobStatus struct {
+	Name      string    `json:"name"`
+	LastRun   time.Time `json:"last_run,omitzero"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Scheduler runs background jobs, each in its own goroutine. A run that
+// is still in progress when Stop is called gets to finish.
+type Scheduler struct {
+	jobs    []Job
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running atomic.Bool
+
+	mu     sync.Mutex
+	status map[string]JobStatus
+}
+
+func NewScheduler(jobs ...Job) *Scheduler {
+	return &Scheduler{jobs: jobs, status: make(map[string]JobStatus, len(jobs))}
+}
+
+// Start runs every job once right away and then on its interval until
+// Stop is called.
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.running.Store(true)
+
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job Job) {
+			defer s.wg.Done()
+			ticker := time.NewTicker(job.Interval)
+			defer ticker.Stop()
+			for {
+				s.run(ctx, job)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}(job)
+	}
+}
+
+// run executes one run of job in its own span, so the storage calls it
+// makes are grouped together.
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	ctx, span := tracing.Tracer().Start(ctx, "job "+job.Name)
+	defer span.End()
+
+	status := JobStatus{Name: job.Name, LastRun: time.Now().UTC()}
+	if err := job.Run(ctx); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.Error("job failed", "job", job.Name, "error", err)
+		status.LastError = err.Error()
+	}
+
+	s.mu.Lock()
+	s.status[job.Name] = status
+	s.mu.Unlock()
+}
+
+// Running reports whether the scheduler has been started and not stopped.
+func (s *Scheduler) Running() bool {
+	return s.running.Load()
+}
+
+// Status returns the latest run of every job, in the order they were
+// registered. Jobs that have not run yet only have a name.
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]JobStatus, len(s.jobs))
+	for i, job := range s.jobs {
+		statuses[i] = s.status[job.Name]
+		statuses[i].Name = job.Name
+	}
+	return statuses
+}
+
+// Stop cancels the jobs and waits for running ones to return, or until
+// ctx is done.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.running.Store(false)
+	if s.cancel != nil {
+		s.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
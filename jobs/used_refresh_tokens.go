@@ -0,0 +1,29 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/leo140803/finance-app-backend/config"
+)
+
+// UsedRefreshTokenCleanup forgets exchanged refresh tokens once they are
+// older than the configured retention.
+func UsedRefreshTokenCleanup() Job {
+	return Job{
+		Name:     "used-refresh-token-cleanup",
+		Interval: 6 * time.Hour,
+		Run:      deleteOldRefreshTokens,
+	}
+}
+
+func deleteOldRefreshTokens(ctx context.Context) error {
+	retention := time.Duration(config.Auth.UsedRefreshTokenDays) * 24 * time.Hour
+	cutoff := time.Now().UTC().Add(-retention).Format(time.RFC3339)
+	err := config.DB.From("used_refresh_tokens").Delete().Lt("used_at", cutoff).Execute(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("delete used refresh tokens: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// FileMailer writes every message as an .eml file into Dir instead of
+// sending it, so links can be picked up locally without a mail server.
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{Dir: dir, From: from}
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405.000000"), fileSafe(msg.To))
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, format(m.From, msg), 0o600); err != nil {
+		return err
+	}
+	slog.Info("mail written", "to", msg.To, "path", path)
+	return nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9@._-]`)
+
+// fileSafe turns an address into a file name that stays inside Dir.
+func fileSafe(addr string) string {
+	return unsafeFileChars.ReplaceAllString(filepath.Base(addr), "_")
+}
@@ -0,0 +1,39 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailerName(t *testing.T) {
+	tests := []struct {
+		to   string
+		want string
+	}{
+		{"user@example.com", "-user@example.com.eml"},
+		{"../../etc/passwd", "-passwd.eml"},
+		{"a/b@example.com", "-b@example.com.eml"},
+		{"Name <user@example.com>", "-Name__user@example.com_.eml"},
+		{"..", "-...eml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.to, func(t *testing.T) {
+			dir := t.TempDir()
+			m := NewFileMailer(dir, "noreply@example.com")
+			if err := m.Send(context.Background(), Message{To: tt.to, Subject: "Hi"}); err != nil {
+				t.Fatal(err)
+			}
+
+			files, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 1 || !strings.HasSuffix(files[0].Name(), tt.want) {
+				t.Fatalf("files in %s = %v, want one ending in %s", filepath.Base(dir), files, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+// Package metrics defines the Prometheus collectors exposed on /metrics.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time to handle HTTP requests, by method, route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	StorageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "storage_request_duration_seconds",
+		Help:    "Latency of Supabase database and Auth requests, by table and operation; Auth calls have the table \"auth\".",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"table", "operation"})
+
+	StorageErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "storage_errors_total",
+		Help: "Failed Supabase database and Auth requests, by table and operation.",
+	}, []string{"table", "operation"})
+
+	TransactionsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "transactions_created_total",
+		Help: "Transactions recorded through the API.",
+	})
+
+	ImportsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "imports_processed_total",
+		Help: "Archive imports, by result (dry_run, restored, invalid or failed).",
+	}, []string{"result"})
+
+	AuthFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_failures_total",
+		Help: "Rejected authentication attempts, by reason.",
+	}, []string{"reason"})
+)
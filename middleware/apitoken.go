@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/background"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/models"
+)
+
+// APITokenPrefix marks personal access tokens so AuthMiddleware can tell
+// them apart from Supabase access tokens.
+const APITokenPrefix = "dtk_"
+
+// HashToken returns the hex SHA-256 of a token so it can be stored and
+// looked up without keeping the token itself.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewAPIToken returns a random personal access token.
+func NewAPIToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return APITokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+var errInvalidAPIToken = errors.New("invalid or expired API token")
+
+// authenticateAPIToken resolves a personal access token to its user ID and
+// scopes.
+func authenticateAPIToken(ctx context.Context, token string) (*models.APIToken, error) {
+	var tokens []models.APIToken
+	err := config.DB.From("api_tokens").Select("*").
+		Eq("token_hash", HashToken(token)).
+		Is("revoked_at", "null").
+		Execute(ctx, &tokens)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errInvalidAPIToken
+	}
+
+	t := tokens[0]
+	expires, err := time.Parse(time.RFC3339, t.ExpiresAt)
+	if err != nil || time.Now().After(expires) {
+		return nil, errInvalidAPIToken
+	}
+
+	background.Go(func() {
+		now := time.Now().UTC().Format(time.RFC3339)
+		err := config.DB.From("api_tokens").
+			Update(map[string]string{"last_used_at": now}).
+			Eq("id", t.ID).
+			Execute(context.WithoutCancel(ctx), nil)
+		if err != nil {
+			slog.Error("failed to update api token last_used_at", "api_token_id", t.ID, "error", err)
+		}
+	})
+	return &t, nil
+}
+
+// requiredScope returns the scope a personal access token needs for the
+// current route: "read" for GET requests and "<resource>:write" for
+// changes, where resource is the first path segment after /api. Auth,
+// token, household and account sharing management routes return "" and
+// are never open to tokens.
+func requiredScope(c *gin.Context) string {
+	path := strings.TrimPrefix(c.FullPath(), "/api/")
+	resource, _, _ := strings.Cut(path, "/")
+
+	if c.Request.Method == http.MethodGet {
+		if resource == "tokens" {
+			return ""
+		}
+		return "read"
+	}
+	if resource == "auth" || resource == "tokens" || resource == "households" {
+		return ""
+	}
+	// Sharing hands an account to another user, which is more than
+	// accounts:write allows
+	if strings.HasPrefix(path, "accounts/:id/shares") {
+		return ""
+	}
+	return resource + ":write"
+}
+
+func hasScope(scopes []string, scope string) bool {
+	return scope != "" && slices.Contains(scopes, scope)
+}
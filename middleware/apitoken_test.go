@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequiredScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		method string
+		route  string
+		path   string
+		want   string
+	}{
+		{http.MethodGet, "/api/accounts", "/api/accounts", "read"},
+		{http.MethodPost, "/api/accounts", "/api/accounts", "accounts:write"},
+		{http.MethodPut, "/api/accounts/:id", "/api/accounts/acc-1", "accounts:write"},
+		{http.MethodGet, "/api/accounts/:id/shares", "/api/accounts/acc-1/shares", "read"},
+		{http.MethodPost, "/api/accounts/:id/shares", "/api/accounts/acc-1/shares", ""},
+		{http.MethodDelete, "/api/accounts/:id/shares/:user_id", "/api/accounts/acc-1/shares/user-1", ""},
+		{http.MethodPost, "/api/households", "/api/households", ""},
+		{http.MethodGet, "/api/tokens", "/api/tokens", ""},
+		{http.MethodPost, "/api/auth/2fa/disable", "/api/auth/2fa/disable", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			var got string
+			r := gin.New()
+			r.Handle(tt.method, tt.route, func(c *gin.Context) { got = requiredScope(c) })
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+
+			if got != tt.want {
+				t.Errorf("requiredScope = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
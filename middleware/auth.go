@@ -2,12 +2,15 @@ package middleware
 
 import (
 	"context"
-	"net/http"
+	"errors"
+	"fmt"
+	"log/slog"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
 	"github.com/leo140803/finance-app-backend/config"
-	"github.com/leo140803/finance-app-backend/models"
+	"github.com/leo140803/finance-app-backend/metrics"
 )
 
 func AuthMiddleware() gin.HandlerFunc {
@@ -15,46 +18,125 @@ func AuthMiddleware() gin.HandlerFunc {
 		// Get token from Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+			metrics.AuthFailures.WithLabelValues("missing_token").Inc()
+			apierror.Respond(c, apierror.ErrUnauthenticated)
 			return
 		}
 
 		// Check if it's a Bearer token
 		if !strings.HasPrefix(authHeader, "Bearer ") {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Bearer token required"})
-			c.Abort()
+			metrics.AuthFailures.WithLabelValues("missing_token").Inc()
+			apierror.Respond(c, apierror.ErrUnauthenticated)
 			return
 		}
 
 		// Extract token
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 
-		// Verify token with Supabase Auth
-		authUser, err := config.SupaClient.Auth.User(context.Background(), token)
+		// Personal access tokens are checked against api_tokens and limited
+		// to their scopes.
+		if strings.HasPrefix(token, APITokenPrefix) {
+			if !config.Features.APITokens {
+				apierror.Respond(c, apierror.ErrDisabled)
+				return
+			}
+			apiToken, err := authenticateAPIToken(c.Request.Context(), token)
+			if errors.Is(err, errInvalidAPIToken) {
+				metrics.AuthFailures.WithLabelValues("invalid_api_token").Inc()
+				apierror.Respond(c, apierror.ErrInvalidToken.Wrap(err))
+				return
+			}
+			if err != nil {
+				apierror.Respond(c, fmt.Errorf("verify API token: %w", err))
+				return
+			}
+			if scope := requiredScope(c); !hasScope(apiToken.Scopes, scope) {
+				metrics.AuthFailures.WithLabelValues("insufficient_scope").Inc()
+				apierror.Respond(c, apierror.ErrInsufficientScope.With("required_scope", scope))
+				return
+			}
+
+			c.Set("user_id", apiToken.UserID)
+			c.Set("api_token_id", apiToken.ID)
+			c.Next()
+			return
+		}
+
+		claims, err := authenticate(c.Request.Context(), token)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token: " + err.Error()})
-			c.Abort()
+			metrics.AuthFailures.WithLabelValues("invalid_token").Inc()
+			apierror.Respond(c, apierror.ErrInvalidToken.Wrap(err))
 			return
 		}
 
-		// Get user from our users table using email from auth user
-		var users []models.User
-		err = config.SupaClient.DB.From("users").Select("*").Eq("email", authUser.Email).Execute(context.Background(), &users)
+		// Map the auth user to our users table (cached)
+		userID, err := resolveUserID(c.Request.Context(), claims.Email)
+		if errors.Is(err, errUserNotFound) {
+			apierror.Respond(c, apierror.ErrUserNotFound)
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user: " + err.Error()})
-			c.Abort()
+			apierror.Respond(c, fmt.Errorf("fetch user: %w", err))
 			return
 		}
 
-		if len(users) == 0 {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found in database"})
-			c.Abort()
+		if claims.SessionID != "" {
+			err = checkSession(c, claims.SessionID, userID)
+		} else {
+			// Without a session there is no record of a passed second
+			// factor.
+			var twoFactor bool
+			twoFactor, err = twoFactorEnabled(c.Request.Context(), userID)
+			if err == nil && twoFactor {
+				err = errSecondFactorRequired
+			}
+		}
+		switch {
+		case errors.Is(err, errSessionRevoked):
+			metrics.AuthFailures.WithLabelValues("revoked_session").Inc()
+			apierror.Respond(c, apierror.ErrSessionRevoked)
+			return
+		case errors.Is(err, errSecondFactorRequired):
+			metrics.AuthFailures.WithLabelValues("second_factor_required").Inc()
+			apierror.Respond(c, apierror.ErrSecondFactorRequired)
+			return
+		case err != nil:
+			apierror.Respond(c, fmt.Errorf("check session: %w", err))
 			return
 		}
+		if claims.SessionID != "" {
+			c.Set("session_id", claims.SessionID)
+		}
+		c.Set("auth_user_id", claims.Subject)
 
 		// Set user ID from our database (not from Supabase Auth)
-		c.Set("user_id", users[0].ID)
+		c.Set("user_id", userID)
 		c.Next()
 	}
 }
+
+// authenticate returns the claims of a valid access token. Tokens are
+// verified locally when keys are configured; Supabase Auth is only asked
+// when that is not possible and remote fallback is enabled.
+func authenticate(ctx context.Context, token string) (*accessClaims, error) {
+	if config.Auth.LocalVerification() {
+		claims, err := verifyToken(token)
+		if err == nil {
+			return claims, nil
+		}
+		if !errors.Is(err, errCannotVerify) || !config.Auth.RemoteFallback {
+			return nil, err
+		}
+		slog.Debug("falling back to Supabase Auth", "reason", err)
+	}
+
+	authUser, err := config.SupaClient.Auth.User(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	// Supabase has vouched for the token, so its remaining claims can be
+	// read without verifying the signature again.
+	claims := &accessClaims{Email: authUser.Email, SessionID: SessionID(token)}
+	claims.Subject = authUser.ID
+	return claims, nil
+}
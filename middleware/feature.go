@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+)
+
+// Feature rejects requests to a part of the API that is switched off in
+// the configuration with 503 FEATURE_UNAVAILABLE.
+func Feature(enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !enabled {
+			apierror.Respond(c, apierror.ErrDisabled)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/models"
+)
+
+// HouseholdHeader selects the household a request works on. Without it,
+// requests work on the user's personal data.
+const HouseholdHeader = "X-Household-ID"
+
+// Workspace resolves the household selected by the X-Household-ID header
+// (or the household_id query parameter, for plain download links) and
+// makes sure the user is a member. It sets household_id and household_role
+// for the handlers and must run after AuthMiddleware.
+func Workspace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		householdID := c.GetHeader(HouseholdHeader)
+		if householdID == "" {
+			householdID = c.Query("household_id")
+		}
+		if householdID == "" {
+			c.Next()
+			return
+		}
+		if !config.Features.Households {
+			apierror.Respond(c, apierror.ErrDisabled)
+			return
+		}
+
+		role, err := HouseholdRole(c.Request.Context(), householdID, c.GetString("user_id"))
+		if err != nil {
+			apierror.Respond(c, fmt.Errorf("check household membership: %w", err))
+			return
+		}
+		if role == "" {
+			apierror.Respond(c, apierror.ErrHouseholdNotFound)
+			return
+		}
+
+		c.Set("household_id", householdID)
+		c.Set("household_role", role)
+		c.Next()
+	}
+}
+
+// HouseholdRole returns the user's role in the household, or "" when they
+// are not a member.
+func HouseholdRole(ctx context.Context, householdID, userID string) (string, error) {
+	var members []models.HouseholdMember
+	err := config.DB.From("household_members").Select("role").
+		Eq("household_id", householdID).
+		Eq("user_id", userID).
+		Execute(ctx, &members)
+	if err != nil || len(members) == 0 {
+		return "", err
+	}
+	return members[0].Role, nil
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// jwksRefreshInterval limits how often an unknown key ID can trigger a
+// new download of the key set.
+const jwksRefreshInterval = time.Minute
+
+var errUnknownKey = errors.New("unknown signing key")
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// jwksCache holds the public keys published at a JWKS URL, refreshing them
+// when a token is signed with a key ID it has not seen yet.
+type jwksCache struct {
+	url    string
+	client *http.Client
+
+	mu      sync.RWMutex
+	keys    map[string]any
+	fetched time.Time
+}
+
+func newJWKSCache(url string) *jwksCache {
+	return &jwksCache{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		keys:   map[string]any{},
+	}
+}
+
+func (j *jwksCache) key(kid string) (any, error) {
+	j.mu.RLock()
+	key, ok := j.keys[kid]
+	fetched := j.fetched
+	j.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if time.Since(fetched) < jwksRefreshInterval {
+		return nil, errUnknownKey
+	}
+
+	if err := j.refresh(); err != nil {
+		return nil, err
+	}
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errUnknownKey
+}
+
+func (j *jwksCache) refresh() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if time.Since(j.fetched) < jwksRefreshInterval {
+		return nil
+	}
+	j.fetched = time.Now()
+
+	resp, err := j.client.Get(j.url)
+	if err != nil {
+		return fmt.Errorf("fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch JWKS: unexpected status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	j.keys = keys
+	return nil
+}
+
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/logging"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID to and from clients and proxies.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds request IDs accepted from clients.
+const maxRequestIDLength = 128
+
+// RequestID reuses the X-Request-ID sent by the client or a proxy, or
+// generates one, and echoes it in the response. The request's context
+// carries a logger tagged with the ID and, when tracing is on, the trace
+// ID.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		c.Set("request_id", id)
+		c.Header(RequestIDHeader, id)
+		logger := slog.Default().With("request_id", id)
+		if sc := trace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+			logger = logger.With("trace_id", sc.TraceID().String())
+		}
+		c.Request = c.Request.WithContext(logging.WithLogger(c.Request.Context(), logger))
+		c.Next()
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Log returns the logger for the current request, tagged with its request
+// ID, route and authenticated user.
+func Log(c *gin.Context) *slog.Logger {
+	logger := logging.FromContext(c.Request.Context()).With("route", c.FullPath())
+	if userID := c.GetString("user_id"); userID != "" {
+		logger = logger.With("user_id", userID)
+	}
+	return logger
+}
+
+// Logger writes one line per request once it has been handled. The query
+// string is left out because it can carry tokens.
+func Logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", status,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"bytes", c.Writer.Size(),
+			"ip", c.ClientIP(),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", c.Errors.String())
+		}
+		Log(c).Log(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Recovery turns panics into a 500 response and logs them with the stack.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err any) {
+		Log(c).Error("panic recovered", "error", fmt.Sprint(err), "stack", string(debug.Stack()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, apierror.Body(c, apierror.ErrInternal))
+	})
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/metrics"
+)
+
+// Metrics counts requests and records their latency by route template, so
+// /accounts/:id is one series no matter how many accounts exist.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		metrics.HTTPDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// MetricsAuth protects /metrics with a bearer token. Without one the
+// endpoint is open and should only be reachable from the internal network.
+func MetricsAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+		got := c.GetHeader("Authorization")
+		if subtle.ConstantTimeCompare([]byte(got), []byte("Bearer "+token)) != 1 {
+			apierror.Respond(c, apierror.ErrInvalidToken)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/config"
+)
+
+// RateLimit allows each client IP at most limit requests per window on the
+// routes it is attached to. Routes sharing a name share the budget. If the
+// store is unavailable requests are let through rather than locking
+// everyone out.
+func RateLimit(name string, limit int, window time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if allow(c, name, fmt.Sprintf("ip:%s:%s", name, c.ClientIP()), limit, window) {
+			c.Next()
+		}
+	}
+}
+
+// Names of the per-email limits.
+const (
+	RegisterLimit       = "register"
+	ForgotPasswordLimit = "forgot_password"
+)
+
+// LoginLockKey is the config.LoginLockout key of an email address.
+func LoginLockKey(email string) string {
+	return "login:" + strings.ToLower(email)
+}
+
+// LimitEmail allows at most limit requests per window for one email
+// address, on top of the per-IP limit, so spreading requests over many
+// addresses does not help. It responds 429 and returns false once the
+// limit is reached.
+func LimitEmail(c *gin.Context, name, email string, limit int, window time.Duration) bool {
+	return allow(c, name, EmailLimitKey(name, email), limit, window)
+}
+
+// EmailLimitKey is the counter key LimitEmail uses. The address is hashed
+// so the store does not hold it.
+func EmailLimitKey(name, email string) string {
+	return fmt.Sprintf("email:%s:%s", name, HashToken(strings.ToLower(email)))
+}
+
+func allow(c *gin.Context, name, key string, limit int, window time.Duration) bool {
+	count, ttl, err := config.RateLimitStore.Incr(c.Request.Context(), key, window)
+	if err != nil {
+		Log(c).Error("rate limit store failed", "limit", name, "error", err)
+		return true
+	}
+
+	c.Header("X-RateLimit-Limit", fmt.Sprint(limit))
+	c.Header("X-RateLimit-Remaining", fmt.Sprint(max(limit-count, 0)))
+	if count > limit {
+		TooManyRequests(c, ttl, apierror.ErrRateLimited)
+		return false
+	}
+	return true
+}
+
+// TooManyRequests writes e, a 429 error, with a Retry-After header and
+// aborts the request.
+func TooManyRequests(c *gin.Context, retryAfter time.Duration, e *apierror.Error) {
+	seconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+	c.Header("Retry-After", fmt.Sprint(seconds))
+	apierror.Respond(c, e.With("retry_after", seconds))
+}
@@ -0,0 +1,251 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/leo140803/finance-app-backend/background"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/models"
+)
+
+const (
+	// sessionCacheTTL bounds how long another instance may keep accepting
+	// a session after it has been revoked.
+	sessionCacheTTL = 30 * time.Second
+	// lastSeenInterval throttles last_seen_at writes.
+	lastSeenInterval = 5 * time.Minute
+)
+
+var (
+	errSessionRevoked       = errors.New("session has been revoked")
+	errSecondFactorRequired = errors.New("session has not passed the second factor")
+)
+
+type sessionState struct {
+	revoked bool
+	// needsSecondFactor is set when the user has 2FA enabled but the
+	// session did not pass it, e.g. a token taken from Supabase directly.
+	needsSecondFactor bool
+	lastSeen          time.Time
+	checked           time.Time
+}
+
+var sessionCache = struct {
+	sync.Mutex
+	entries map[string]*sessionState
+}{entries: map[string]*sessionState{}}
+
+// SessionID returns the session_id claim of a Supabase access token without
+// verifying it. Only use it on tokens that were just issued by Supabase or
+// already verified.
+func SessionID(accessToken string) string {
+	var claims accessClaims
+	if _, _, err := jwt.NewParser().ParseUnverified(accessToken, &claims); err != nil {
+		return ""
+	}
+	return claims.SessionID
+}
+
+func newSession(c *gin.Context, id, userID string, secondFactor bool) models.Session {
+	ua := c.Request.UserAgent()
+	device := c.GetHeader("X-Device-Name")
+	if device == "" {
+		device = deviceName(ua)
+	}
+	return models.Session{
+		ID:           id,
+		UserID:       userID,
+		Device:       device,
+		IPAddress:    c.ClientIP(),
+		UserAgent:    ua,
+		LastSeenAt:   time.Now().UTC().Format(time.RFC3339),
+		SecondFactor: secondFactor,
+	}
+}
+
+// StartSession records the session of a freshly issued access token, e.g.
+// after login. secondFactor tells whether the login passed 2FA. A session
+// that is not recorded could not be listed or revoked, so the sign-in fails
+// with the error.
+func StartSession(c *gin.Context, userID, accessToken string, secondFactor bool) error {
+	id := SessionID(accessToken)
+	if id == "" {
+		return nil
+	}
+	// A nil result would drop the upsert Prefer header
+	var result interface{}
+	return config.DB.From("sessions").
+		Upsert(newSession(c, id, userID, secondFactor)).
+		Execute(c.Request.Context(), &result)
+}
+
+// PassSecondFactor marks a session as having passed 2FA, e.g. the one that
+// just enabled it.
+func PassSecondFactor(ctx context.Context, id string) error {
+	err := config.DB.From("sessions").
+		Update(map[string]bool{"second_factor": true}).
+		Eq("id", id).
+		Execute(ctx, nil)
+	ForgetSession(id)
+	return err
+}
+
+// twoFactorEnabled reports whether the user has switched 2FA on.
+func twoFactorEnabled(ctx context.Context, userID string) (bool, error) {
+	var rows []struct {
+		Enabled bool `json:"enabled"`
+	}
+	err := config.DB.From("two_factor").Select("enabled").Eq("user_id", userID).Execute(ctx, &rows)
+	if err != nil {
+		return false, err
+	}
+	return len(rows) > 0 && rows[0].Enabled, nil
+}
+
+// ForgetSession drops the cached state of a session so a revocation takes
+// effect immediately on this instance.
+func ForgetSession(id string) {
+	sessionCache.Lock()
+	delete(sessionCache.entries, id)
+	sessionCache.Unlock()
+}
+
+// checkSession rejects requests from revoked sessions and, for users with
+// 2FA enabled, from sessions that did not pass it. It keeps last_seen_at
+// current. Sessions that are not tracked yet, such as those created before
+// session tracking existed, are recorded on first use; the request fails
+// when they cannot be. For users with 2FA they are rejected instead, since
+// only LoginTwoFactor starts their sessions.
+func checkSession(c *gin.Context, id, userID string) error {
+	now := time.Now()
+
+	sessionCache.Lock()
+	state, ok := sessionCache.entries[id]
+	if ok && now.Sub(state.checked) < sessionCacheTTL {
+		revoked, needsSecondFactor := state.revoked, state.needsSecondFactor
+		seen := now.Sub(state.lastSeen) < lastSeenInterval
+		sessionCache.Unlock()
+		if revoked {
+			return errSessionRevoked
+		}
+		if needsSecondFactor {
+			return errSecondFactorRequired
+		}
+		if !seen {
+			touchSession(c.Request.Context(), id, userID)
+		}
+		return nil
+	}
+	sessionCache.Unlock()
+
+	ctx := c.Request.Context()
+	var rows []models.Session
+	err := config.DB.From("sessions").Select("*").
+		Eq("id", id).
+		Eq("user_id", userID).
+		Execute(ctx, &rows)
+	if err != nil {
+		return err
+	}
+
+	twoFactor, err := twoFactorEnabled(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	state = &sessionState{checked: now}
+	switch {
+	case len(rows) > 0:
+		state.revoked = rows[0].RevokedAt != nil
+		state.needsSecondFactor = twoFactor && !rows[0].SecondFactor
+		state.lastSeen, _ = time.Parse(time.RFC3339, rows[0].LastSeenAt)
+	case twoFactor:
+		state.needsSecondFactor = true
+		state.lastSeen = now
+	default:
+		var result interface{}
+		session := newSession(c, id, userID, false)
+		if err := config.DB.From("sessions").Upsert(session).Execute(ctx, &result); err != nil {
+			return err
+		}
+		state.lastSeen = now
+	}
+
+	sessionCache.Lock()
+	sessionCache.entries[id] = state
+	sessionCache.Unlock()
+
+	if state.revoked {
+		return errSessionRevoked
+	}
+	if state.needsSecondFactor {
+		return errSecondFactorRequired
+	}
+	if now.Sub(state.lastSeen) >= lastSeenInterval {
+		touchSession(c.Request.Context(), id, userID)
+	}
+	return nil
+}
+
+func touchSession(ctx context.Context, id, userID string) {
+	now := time.Now()
+	sessionCache.Lock()
+	if state, ok := sessionCache.entries[id]; ok {
+		state.lastSeen = now
+	}
+	sessionCache.Unlock()
+
+	background.Go(func() {
+		err := config.DB.From("sessions").
+			Update(map[string]string{"last_seen_at": now.UTC().Format(time.RFC3339)}).
+			Eq("id", id).
+			Eq("user_id", userID).
+			Execute(context.WithoutCancel(ctx), nil)
+		if err != nil {
+			slog.Error("failed to update session last_seen_at", "session_id", id, "user_id", userID, "error", err)
+		}
+	})
+}
+
+// deviceName gives a short description like "Chrome on Windows" for a
+// User-Agent string.
+func deviceName(ua string) string {
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"okhttp", "Android app"},
+		{"Dart/", "Flutter app"},
+		{"curl/", "curl"},
+		{"PostmanRuntime", "Postman"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	for _, o := range []struct{ token, name string }{
+		{"Windows", "Windows"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.token) {
+			return browser + " on " + o.name
+		}
+	}
+	return browser
+}
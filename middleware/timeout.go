@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/db"
+)
+
+// Timeout cancels the request's context after d, or after bulk for routes
+// under one of bulkPrefixes. When the deadline or a storage call's own
+// timeout expires, the handler's error response is replaced by a TIMEOUT
+// error so clients always see timeouts the same way. Responses that have already
+// started, such as streamed exports, are left alone.
+func Timeout(d, bulk time.Duration, bulkPrefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := d
+		for _, prefix := range bulkPrefixes {
+			if strings.HasPrefix(c.FullPath(), prefix) {
+				limit = bulk
+			}
+		}
+
+		ctx := c.Request.Context()
+		if limit > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, limit)
+			defer cancel()
+		}
+		ctx, storageTimedOut := db.TrackTimeouts(ctx)
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Writer = &timeoutWriter{
+			ResponseWriter: c.Writer,
+			timedOut: func() bool {
+				return storageTimedOut() || errors.Is(ctx.Err(), context.DeadlineExceeded)
+			},
+			body: func() ([]byte, error) {
+				return json.Marshal(apierror.Body(c, apierror.ErrTimeout))
+			},
+		}
+		c.Next()
+	}
+}
+
+type timeoutWriter struct {
+	gin.ResponseWriter
+	timedOut func() bool
+	body     func() ([]byte, error)
+	replaced bool
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if code >= http.StatusBadRequest && !w.Written() && w.timedOut() {
+		w.replaced = true
+		code = apierror.ErrTimeout.Status
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	if !w.replaced {
+		return w.ResponseWriter.Write(b)
+	}
+	if !w.Written() {
+		body, err := w.body()
+		if err != nil {
+			return 0, err
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if _, err := w.ResponseWriter.Write(body); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/models"
+)
+
+type cachedUserID struct {
+	id      string
+	expires time.Time
+}
+
+// userIDs maps an auth user's email to the id of its row in our users
+// table, so an authenticated request does not need a database lookup.
+var userIDs = struct {
+	sync.Mutex
+	entries map[string]cachedUserID
+}{entries: map[string]cachedUserID{}}
+
+// errUserNotFound is returned when the auth user has no users row.
+var errUserNotFound = errors.New("user not found in database")
+
+func resolveUserID(ctx context.Context, email string) (string, error) {
+	userIDs.Lock()
+	entry, ok := userIDs.entries[email]
+	userIDs.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.id, nil
+	}
+
+	var users []models.User
+	err := config.DB.From("users").Select("*").Eq("email", email).Execute(ctx, &users)
+	if err != nil {
+		return "", err
+	}
+	if len(users) == 0 {
+		return "", errUserNotFound
+	}
+
+	userIDs.Lock()
+	userIDs.entries[email] = cachedUserID{id: users[0].ID, expires: time.Now().Add(config.Auth.UserCacheTTL)}
+	userIDs.Unlock()
+	return users[0].ID, nil
+}
+
+// ForgetUser drops the cached user ID for email, e.g. once the user has
+// been erased.
+func ForgetUser(email string) {
+	userIDs.Lock()
+	delete(userIDs.entries, email)
+	userIDs.Unlock()
+}
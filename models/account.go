@@ -1,14 +1,43 @@
 package models
 
+// Account is both the stored row and the body of the create endpoint; the
+// binding rules apply to request bodies only.
 type Account struct {
 	ID             string  `json:"id,omitempty"`
 	UserID         string  `json:"user_id"`
-	Name           string  `json:"name"`
-	InitialBalance float64 `json:"initial_balance"`
+	HouseholdID    string  `json:"household_id,omitempty"`
+	Name           string  `json:"name" binding:"required,notblank,max=100"`
+	InitialBalance float64 `json:"initial_balance" binding:"gte=-99999999.99,lte=99999999.99"`
 	CreatedAt      string  `json:"created_at,omitempty"`
+	// Access is set on accounts shared with the requesting user; it is
+	// not stored on the row.
+	Access string `json:"access,omitempty"`
 }
 
 // struct khusus untuk update saldo
 type UpdateAccountBalance struct {
 	InitialBalance float64 `json:"initial_balance"`
 }
+
+// Access levels for accounts shared with other users. "read" shows the
+// account and its transactions; "write" also allows recording, editing and
+// deleting its transactions.
+const (
+	AccessRead  = "read"
+	AccessWrite = "write"
+)
+
+// AccountShare grants another user access to one personal account.
+type AccountShare struct {
+	AccountID string `json:"account_id"`
+	UserID    string `json:"user_id"`
+	Access    string `json:"access"`
+	CreatedAt string `json:"created_at,omitempty"`
+	// Email is filled in from the users table when listing shares.
+	Email string `json:"email,omitempty"`
+}
+
+type ShareAccountRequest struct {
+	Email  string `json:"email" binding:"required,email"`
+	Access string `json:"access" binding:"required,oneof=read write"`
+}
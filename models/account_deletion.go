@@ -0,0 +1,19 @@
+package models
+
+// AccountDeletion is a pending request to erase a user. The data is
+// removed by a background job once ScheduledFor has passed, unless the
+// request is cancelled before then.
+type AccountDeletion struct {
+	UserID       string `json:"user_id"`
+	AuthUserID   string `json:"auth_user_id,omitempty"`
+	Email        string `json:"email,omitempty"`
+	RequestedAt  string `json:"requested_at,omitempty"`
+	ScheduledFor string `json:"scheduled_for"`
+}
+
+// DeleteAccountRequest re-authenticates the user before scheduling the
+// deletion. Code is required when 2FA is enabled.
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code"`
+}
@@ -0,0 +1,31 @@
+package models
+
+// APIToken is a personal access token for scripts and integrations. Only a
+// hash of the token is stored; the token itself is shown once on creation.
+type APIToken struct {
+	ID         string   `json:"id,omitempty"`
+	UserID     string   `json:"user_id,omitempty"`
+	Name       string   `json:"name"`
+	TokenHash  string   `json:"token_hash,omitempty"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  string   `json:"expires_at"`
+	LastUsedAt *string  `json:"last_used_at,omitempty"`
+	RevokedAt  *string  `json:"revoked_at,omitempty"`
+	CreatedAt  string   `json:"created_at,omitempty"`
+}
+
+// CreateAPITokenRequest creates a token with the given scopes. "read"
+// allows every GET endpoint; the write scopes allow changes to one kind of
+// resource. Tokens expire after ExpiresInDays (default 90).
+type CreateAPITokenRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1,dive,oneof=read accounts:write categories:write transactions:write import:write"`
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365"`
+}
+
+// CreateAPITokenResponse is the only response that contains the token.
+type CreateAPITokenResponse struct {
+	APIToken
+	Token string `json:"token"`
+}
@@ -0,0 +1,25 @@
+package models
+
+// ArchiveVersion is bumped whenever the archive layout changes in a way
+// that older restore code cannot read.
+const ArchiveVersion = 1
+
+// Archive is the full-account JSON export. IDs are kept as they were in
+// the source so references between records can be remapped on restore.
+// The records' binding rules are not applied on restore, see
+// handlers.validateArchive.
+type Archive struct {
+	Version      int           `json:"version"`
+	ExportedAt   string        `json:"exported_at"`
+	Accounts     []Account     `json:"accounts"`
+	Categories   []Category    `json:"categories"`
+	Transactions []Transaction `json:"transactions"`
+}
+
+// RestoreResult reports what a restore did, or would do on a dry run.
+type RestoreResult struct {
+	DryRun       bool `json:"dry_run"`
+	Accounts     int  `json:"accounts"`
+	Categories   int  `json:"categories"`
+	Transactions int  `json:"transactions"`
+}
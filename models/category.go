@@ -1,8 +1,15 @@
 package models
 
+// Category is both the stored row and the body of the create endpoint; the
+// binding rules apply to request bodies only.
 type Category struct {
-    ID        string `json:"id,omitempty"`
-    UserID    string `json:"user_id"`
-    Name      string `json:"name"`
-    CreatedAt string `json:"created_at,omitempty"`
+    ID          string `json:"id,omitempty"`
+    UserID      string `json:"user_id"`
+    HouseholdID string `json:"household_id,omitempty"`
+    Name        string `json:"name" binding:"required,notblank,max=50"`
+    CreatedAt   string `json:"created_at,omitempty"`
+}
+
+type UpdateCategoryRequest struct {
+    Name string `json:"name" binding:"required,notblank,max=50"`
 }
@@ -0,0 +1,66 @@
+package models
+
+// Household member roles, from most to least privileged. Owners manage the
+// household and its members, editors change its data and viewers can only
+// read it.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// Household is a shared workspace that owns accounts, categories and
+// transactions on behalf of all its members.
+type Household struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name"`
+	CreatedBy string `json:"created_by,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+	// Role is the requesting user's role; it is not stored on the row.
+	Role string `json:"role,omitempty"`
+}
+
+type HouseholdMember struct {
+	HouseholdID string `json:"household_id"`
+	UserID      string `json:"user_id"`
+	Role        string `json:"role"`
+	CreatedAt   string `json:"created_at,omitempty"`
+	// Email is filled in from the users table when listing members.
+	Email string `json:"email,omitempty"`
+}
+
+// HouseholdInvitation invites an email address to join a household. Only a
+// hash of the token mailed to the invitee is stored.
+type HouseholdInvitation struct {
+	ID          string  `json:"id,omitempty"`
+	HouseholdID string  `json:"household_id"`
+	Email       string  `json:"email"`
+	Role        string  `json:"role"`
+	TokenHash   string  `json:"token_hash,omitempty"`
+	InvitedBy   string  `json:"invited_by,omitempty"`
+	ExpiresAt   string  `json:"expires_at"`
+	AcceptedAt  *string `json:"accepted_at,omitempty"`
+	CreatedAt   string  `json:"created_at,omitempty"`
+}
+
+type HouseholdDetails struct {
+	Household
+	Members []HouseholdMember `json:"members"`
+}
+
+type HouseholdRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner editor viewer"`
+}
+
+type InviteMemberRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=owner editor viewer"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
@@ -0,0 +1,83 @@
+package models
+
+// ReportRange selects the period a report covers: either a whole month or
+// an explicit start/end date. With neither, the current month is used.
+type ReportRange struct {
+	Month     string `form:"month" binding:"omitempty,datetime=2006-01"`
+	StartDate string `form:"start_date" binding:"omitempty,datetime=2006-01-02"`
+	EndDate   string `form:"end_date" binding:"omitempty,datetime=2006-01-02"`
+}
+
+type SummaryReport struct {
+	StartDate    string            `json:"start_date"`
+	EndDate      string            `json:"end_date"`
+	TotalIncome  float64           `json:"total_income"`
+	TotalExpense float64           `json:"total_expense"`
+	Net          float64           `json:"net"`
+	ByCategory   []ReportBreakdown `json:"by_category"`
+	ByAccount    []ReportBreakdown `json:"by_account"`
+}
+
+// ReportBreakdown is the income/expense subtotal for one category or
+// account. ID is empty for uncategorized transactions.
+type ReportBreakdown struct {
+	ID      string  `json:"id"`
+	Name    string  `json:"name"`
+	Income  float64 `json:"income"`
+	Expense float64 `json:"expense"`
+	Net     float64 `json:"net"`
+	Count   int     `json:"count"`
+}
+
+// CashFlowQuery holds the options of the cash-flow report on top of its
+// ReportRange. Timezone is an IANA name such as "Asia/Jakarta" and
+// overrides the one stored in the user's profile.
+type CashFlowQuery struct {
+	Interval   string `form:"interval" binding:"omitempty,oneof=day week month year"`
+	AccountID  string `form:"account_id" binding:"omitempty,uuid"`
+	CategoryID string `form:"category_id" binding:"omitempty,uuid"`
+	Timezone   string `form:"tz"`
+}
+
+type CashFlowReport struct {
+	Interval  string          `json:"interval"`
+	Timezone  string          `json:"timezone"`
+	WeekStart string          `json:"week_start"`
+	StartDate string          `json:"start_date"`
+	EndDate   string          `json:"end_date"`
+	Points    []CashFlowPoint `json:"points"`
+}
+
+// CashFlowPoint is one bucket of the series; both dates are inclusive.
+type CashFlowPoint struct {
+	PeriodStart string  `json:"period_start"`
+	PeriodEnd   string  `json:"period_end"`
+	Income      float64 `json:"income"`
+	Expense     float64 `json:"expense"`
+	Net         float64 `json:"net"`
+}
+
+type NetWorthReport struct {
+	Interval  string          `json:"interval"`
+	Timezone  string          `json:"timezone"`
+	WeekStart string          `json:"week_start"`
+	StartDate string          `json:"start_date"`
+	EndDate   string          `json:"end_date"`
+	Points    []NetWorthPoint `json:"points"`
+}
+
+// NetWorthPoint is the state of every account at the end of one period.
+// Accounts with a negative balance count as liabilities.
+type NetWorthPoint struct {
+	Date        string           `json:"date"`
+	Assets      float64          `json:"assets"`
+	Liabilities float64          `json:"liabilities"`
+	NetWorth    float64          `json:"net_worth"`
+	Accounts    []AccountBalance `json:"accounts"`
+}
+
+type AccountBalance struct {
+	AccountID string  `json:"account_id"`
+	Name      string  `json:"name"`
+	Balance   float64 `json:"balance"`
+}
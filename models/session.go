@@ -0,0 +1,23 @@
+package models
+
+// Session is a signed-in device. ID is the Supabase Auth session ID carried
+// in the session_id claim of its access tokens. SecondFactor is set for
+// sessions that passed 2FA; users with 2FA enabled can only use those.
+type Session struct {
+	ID           string  `json:"id"`
+	UserID       string  `json:"user_id,omitempty"`
+	Device       string  `json:"device"`
+	IPAddress    string  `json:"ip_address"`
+	UserAgent    string  `json:"user_agent"`
+	LastSeenAt   string  `json:"last_seen_at,omitempty"`
+	RevokedAt    *string `json:"revoked_at,omitempty"`
+	SecondFactor bool    `json:"second_factor"`
+	CreatedAt    string  `json:"created_at,omitempty"`
+}
+
+// SessionResponse is a session as listed to its user. Current marks the
+// session making the request; it is not stored on the row.
+type SessionResponse struct {
+	Session
+	Current bool `json:"current"`
+}
@@ -1,14 +1,28 @@
 package models
 
+// Transaction is both the stored row and the body of the create and update
+// endpoints; the binding rules apply to request bodies only. Amounts are
+// capped at what the DECIMAL(10,2) columns can hold.
 type Transaction struct {
     ID          string  `json:"id,omitempty"`
     UserID      string  `json:"user_id"`
-    AccountID   string  `json:"account_id"`
-    CategoryID  string  `json:"category_id,omitempty"`
-    Date        string  `json:"date"`
-    Description string  `json:"description,omitempty"`
-    Amount      float64 `json:"amount"`
-    Type        string  `json:"type"` // "INCOME" atau "EXPENSE"
+    HouseholdID string  `json:"household_id,omitempty"`
+    AccountID   string  `json:"account_id" binding:"omitempty,uuid"`
+    CategoryID  string  `json:"category_id,omitempty" binding:"omitempty,uuid"`
+    Date        string  `json:"date" binding:"omitempty,datetime=2006-01-02"`
+    Description string  `json:"description,omitempty" binding:"max=255"`
+    Amount      float64 `json:"amount" binding:"gt=0,lte=99999999.99"`
+    Type        string  `json:"type" binding:"required,oneof=INCOME EXPENSE"` // "INCOME" atau "EXPENSE"
     BalanceAfter float64 `json:"balance_after"`
     CreatedAt   string  `json:"created_at,omitempty"`
 }
+
+// TransactionFilter holds the optional query parameters shared by the
+// transaction listing and export endpoints.
+type TransactionFilter struct {
+    StartDate  string `form:"start_date" binding:"omitempty,datetime=2006-01-02"`
+    EndDate    string `form:"end_date" binding:"omitempty,datetime=2006-01-02"`
+    AccountID  string `form:"account_id" binding:"omitempty,uuid"`
+    CategoryID string `form:"category_id" binding:"omitempty,uuid"`
+    Type       string `form:"type" binding:"omitempty,oneof=INCOME EXPENSE"`
+}
@@ -0,0 +1,51 @@
+package models
+
+// TwoFactor is a user's TOTP enrollment. It exists but is not Enabled
+// between setup and the first verified code. Secret is stored encrypted
+// and RecoveryCodes holds SHA-256 hashes of the unused recovery codes.
+type TwoFactor struct {
+	UserID        string   `json:"user_id"`
+	Secret        string   `json:"secret"`
+	Enabled       bool     `json:"enabled"`
+	RecoveryCodes []string `json:"recovery_codes"`
+	LastUsedStep  int64    `json:"last_used_step"`
+	CreatedAt     string   `json:"created_at,omitempty"`
+}
+
+// LoginChallenge holds the tokens of a password login, encrypted, until
+// the second factor has been verified.
+type LoginChallenge struct {
+	ID           string `json:"id,omitempty"`
+	TokenHash    string `json:"token_hash"`
+	UserID       string `json:"user_id"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	Attempts     int    `json:"attempts"`
+	ExpiresAt    string `json:"expires_at"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorRequiredResponse is returned by Login instead of an AuthResponse
+// when the user has 2FA enabled.
+type TwoFactorRequiredResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresAt         string `json:"expires_at"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
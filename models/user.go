@@ -1,9 +1,26 @@
 package models
 
 type User struct {
-	ID        string `json:"id,omitempty"`
-	Email     string `json:"email"`
-	CreatedAt string `json:"created_at,omitempty"`
+	ID               string `json:"id,omitempty"`
+	Email            string `json:"email"`
+	DisplayName      string `json:"display_name,omitempty"`
+	BaseCurrency     string `json:"base_currency,omitempty"`
+	Locale           string `json:"locale,omitempty"`
+	Timezone         string `json:"timezone,omitempty"`
+	WeekStart        string `json:"week_start,omitempty"`
+	DefaultAccountID string `json:"default_account_id,omitempty"`
+	CreatedAt        string `json:"created_at,omitempty"`
+}
+
+// UpdateProfileRequest changes only the fields that are present. An empty
+// display_name or default_account_id clears it.
+type UpdateProfileRequest struct {
+	DisplayName      *string `json:"display_name,omitempty" binding:"omitempty,max=100"`
+	BaseCurrency     *string `json:"base_currency,omitempty" binding:"omitempty,iso4217"`
+	Locale           *string `json:"locale,omitempty" binding:"omitempty,bcp47_language_tag"`
+	Timezone         *string `json:"timezone,omitempty" binding:"omitempty,timezone"`
+	WeekStart        *string `json:"week_start,omitempty" binding:"omitempty,oneof=monday tuesday wednesday thursday friday saturday sunday"`
+	DefaultAccountID *string `json:"default_account_id,omitempty"`
 }
 
 type RegisterRequest struct {
@@ -16,6 +33,36 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// UsedRefreshToken records a refresh token that has already been exchanged,
+// so presenting it again can be detected as reuse.
+type UsedRefreshToken struct {
+	TokenHash string `json:"token_hash,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+	UsedAt    string `json:"used_at,omitempty"`
+}
+
 type AuthResponse struct {
 	User         User   `json:"user"`
 	AccessToken  string `json:"access_token"`
@@ -0,0 +1,289 @@
+// Package openapi describes the API as an OpenAPI 3 document. The routes
+// are listed in operations.go and checked against the router by Check; the
+// schemas are generated from the request and response models.
+package openapi
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/apierror"
+	"github.com/leo140803/finance-app-backend/middleware"
+)
+
+// DocsPage renders the document served at /api/openapi.json with Swagger
+// UI.
+//
+//go:embed docs.html
+var DocsPage []byte
+
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Servers    []Server                         `json:"servers"`
+	Tags       []Tag                            `json:"tags"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+type Operation struct {
+	Tags        []string              `json:"tags"`
+	Summary     string                `json:"summary"`
+	OperationID string                `json:"operationId"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path, query or header parameter, or a $ref to a shared
+// one.
+type Parameter struct {
+	Ref         string  `json:"$ref,omitempty"`
+	Name        string  `json:"name,omitempty"`
+	In          string  `json:"in,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response is a response description, or a $ref to a shared one.
+type Response struct {
+	Ref         string                `json:"$ref,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	Parameters      map[string]*Parameter      `json:"parameters"`
+	Responses       map[string]*Response       `json:"responses"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme"`
+	Description string `json:"description"`
+}
+
+// errorEnvelope is the body of every error response, see apierror.Body.
+type errorEnvelope struct {
+	Error     string                `json:"error" binding:"required"`
+	Code      string                `json:"code" binding:"required"`
+	Details   []apierror.FieldError `json:"details,omitempty"`
+	RequestID string                `json:"request_id,omitempty"`
+}
+
+// Spec returns the OpenAPI document of the API.
+var Spec = sync.OnceValue(build)
+
+func build() *Document {
+	g := &generator{components: map[string]*Schema{}}
+
+	errSchema := g.schema(reflect.TypeOf(errorEnvelope{}))
+	g.components["ErrorEnvelope"].Properties["code"].Enum = apierror.Codes()
+
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "Duitku API",
+			Description: "Personal and household finance tracking. Errors share one envelope with a stable code; see the README for their meaning.",
+			Version:     "1.0.0",
+		},
+		Servers: []Server{{URL: "/"}},
+		Paths:   map[string]map[string]*Operation{},
+		Components: Components{
+			Schemas: g.components,
+			Parameters: map[string]*Parameter{
+				"Household": {
+					Name:        middleware.HouseholdHeader,
+					In:          "header",
+					Description: "Work in this household instead of the personal workspace",
+					Schema:      &Schema{Type: "string", Format: "uuid"},
+				},
+			},
+			Responses: map[string]*Response{
+				"Error": {
+					Description: "Error",
+					Content:     map[string]*MediaType{"application/json": {Schema: errSchema}},
+				},
+			},
+			SecuritySchemes: map[string]*SecurityScheme{
+				"bearerAuth": {
+					Type:        "http",
+					Scheme:      "bearer",
+					Description: "An access token from login or refresh, or a personal access token",
+				},
+			},
+		},
+	}
+
+	for _, op := range operations {
+		if !slices.ContainsFunc(doc.Tags, func(t Tag) bool { return t.Name == op.Tag }) {
+			doc.Tags = append(doc.Tags, Tag{Name: op.Tag})
+		}
+		path := toOpenAPIPath(op.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*Operation{}
+		}
+		doc.Paths[path][strings.ToLower(op.Method)] = op.document(g)
+	}
+	return doc
+}
+
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+// toOpenAPIPath turns a gin path such as /accounts/:id into /accounts/{id}.
+func toOpenAPIPath(path string) string {
+	return pathParam.ReplaceAllString(path, "{$1}")
+}
+
+// operationID names an operation after its method and path, e.g.
+// getHouseholdsByIdInvitations.
+func operationID(method, path string) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(method))
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/api/"), "/") {
+		if name, ok := strings.CutPrefix(segment, ":"); ok {
+			id.WriteString("By")
+			segment = name
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			id.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return id.String()
+}
+
+func (op operation) document(g *generator) *Operation {
+	doc := &Operation{
+		Tags:        []string{op.Tag},
+		Summary:     op.Summary,
+		OperationID: operationID(op.Method, op.Path),
+		Responses:   map[string]*Response{"default": {Ref: "#/components/responses/Error"}},
+	}
+
+	for _, m := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+		doc.Parameters = append(doc.Parameters, &Parameter{Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	for _, q := range op.Query {
+		if p, ok := q.(*Parameter); ok {
+			doc.Parameters = append(doc.Parameters, p)
+			continue
+		}
+		doc.Parameters = append(doc.Parameters, g.queryParameters(reflect.TypeOf(q))...)
+	}
+	if !op.Public {
+		doc.Parameters = append(doc.Parameters, &Parameter{Ref: "#/components/parameters/Household"})
+		doc.Security = []map[string][]string{{"bearerAuth": {}}}
+	}
+
+	if op.Body != nil {
+		body := &RequestBody{Required: true, Content: map[string]*MediaType{
+			"application/json": {Schema: g.schema(reflect.TypeOf(op.Body))},
+		}}
+		if op.Upload {
+			body.Content["multipart/form-data"] = &MediaType{Schema: &Schema{
+				Type:       "object",
+				Properties: map[string]*Schema{"file": {Type: "string", Format: "binary"}},
+				Required:   []string{"file"},
+			}}
+		}
+		doc.RequestBody = body
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := &Response{Description: http.StatusText(status), Content: map[string]*MediaType{}}
+	switch r := op.Response.(type) {
+	case nil:
+		resp.Content["application/json"] = &MediaType{Schema: g.schema(reflect.TypeOf(message{}))}
+	case oneOf:
+		s := &Schema{}
+		for _, v := range r {
+			s.OneOf = append(s.OneOf, g.schema(reflect.TypeOf(v)))
+		}
+		resp.Content["application/json"] = &MediaType{Schema: s}
+	case download:
+		for _, contentType := range r {
+			resp.Content[contentType] = &MediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+		}
+	default:
+		resp.Content["application/json"] = &MediaType{Schema: g.schema(reflect.TypeOf(r))}
+	}
+	doc.Responses[strconv.Itoa(status)] = resp
+	for code, v := range op.Other {
+		doc.Responses[strconv.Itoa(code)] = &Response{
+			Description: http.StatusText(code),
+			Content:     map[string]*MediaType{"application/json": {Schema: g.schema(reflect.TypeOf(v))}},
+		}
+	}
+	return doc
+}
+
+// Check compares the /api routes of a router with the documented
+// operations and lists the routes without documentation and the
+// operations without a route.
+func Check(routes gin.RoutesInfo) error {
+	documented := make(map[string]bool, len(operations))
+	for _, op := range operations {
+		documented[op.Method+" "+op.Path] = false
+	}
+
+	var problems []string
+	for _, r := range routes {
+		if !strings.HasPrefix(r.Path, "/api/") {
+			continue
+		}
+		key := r.Method + " " + r.Path
+		if _, ok := documented[key]; !ok {
+			problems = append(problems, key+" is not documented")
+			continue
+		}
+		documented[key] = true
+	}
+	for key, found := range documented {
+		if !found {
+			problems = append(problems, key+" is documented but not routed")
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	slices.Sort(problems)
+	return fmt.Errorf("openapi: the spec does not match the routes:\n  %s", strings.Join(problems, "\n  "))
+}
@@ -0,0 +1,231 @@
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/leo140803/finance-app-backend/jobs"
+	"github.com/leo140803/finance-app-backend/models"
+)
+
+// operation documents one route. Body, Response and Other hold zero values
+// of the types the handler binds and responds with; their schemas are
+// generated from the json and binding tags.
+type operation struct {
+	Method  string
+	Path    string // as registered with gin, e.g. /api/accounts/:id
+	Tag     string
+	Summary string
+	// Public operations need no bearer token
+	Public bool
+	// Query holds structs bound with ShouldBindQuery and single *Parameter
+	Query []any
+	Body  any
+	// Upload also accepts Body as a multipart file field named "file"
+	Upload bool
+	// Status is the success status, 200 by default
+	Status int
+	// Response is the success body: a model, a oneOf, a download, or nil
+	// for {"message": "..."}
+	Response any
+	// Other documents further non-error responses by status
+	Other map[int]any
+}
+
+// oneOf documents a response that is one of several models.
+type oneOf []any
+
+// download documents a file response by its content types.
+type download []string
+
+// Response bodies the handlers build with gin.H.
+type (
+	message struct {
+		Message string `json:"message" binding:"required"`
+	}
+	accountCreated struct {
+		Message        string  `json:"message"`
+		Name           string  `json:"name"`
+		InitialBalance float64 `json:"initial_balance"`
+		UserID         string  `json:"user_id"`
+	}
+	categoryCreated struct {
+		Message string `json:"message"`
+		Name    string `json:"name"`
+		UserID  string `json:"user_id"`
+	}
+	transactionCreated struct {
+		Message      string  `json:"message"`
+		AccountID    string  `json:"account_id"`
+		Amount       float64 `json:"amount"`
+		Type         string  `json:"type"`
+		UserID       string  `json:"user_id"`
+		BalanceAfter float64 `json:"balance_after"`
+	}
+	deletionScheduled struct {
+		Message      string `json:"message"`
+		ScheduledFor string `json:"scheduled_for"`
+	}
+	sessionsRevoked struct {
+		Message string `json:"message"`
+		Revoked int    `json:"revoked"`
+	}
+	health struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	readiness struct {
+		Status string `json:"status" binding:"oneof=ready unavailable"`
+		Checks struct {
+			Storage struct {
+				Status     string `json:"status" binding:"oneof=ok unavailable"`
+				DurationMS int64  `json:"duration_ms"`
+			} `json:"storage"`
+			Scheduler struct {
+				Status string           `json:"status" binding:"oneof=ok stopped"`
+				Jobs   []jobs.JobStatus `json:"jobs"`
+			} `json:"scheduler"`
+		} `json:"checks"`
+	}
+)
+
+var (
+	exportFormat = &Parameter{
+		Name: "format", In: "query",
+		Schema: &Schema{Type: "string", Enum: []string{"csv", "json", "xlsx"}},
+	}
+	exportFiles = download{"text/csv", "application/json", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"}
+	dryRun      = &Parameter{
+		Name: "dry_run", In: "query", Description: "Only validate the archive; true unless set to false",
+		Schema: &Schema{Type: "boolean"},
+	}
+)
+
+// operations lists every /api route. Check fails when a route is added
+// without an entry here.
+var operations = []operation{
+	// Health
+	{Method: "GET", Path: "/api/health", Tag: "Health", Summary: "Liveness check", Public: true, Response: health{}},
+	{Method: "GET", Path: "/api/health/live", Tag: "Health", Summary: "Liveness check", Public: true, Response: health{}},
+	{Method: "GET", Path: "/api/health/ready", Tag: "Health", Summary: "Readiness check of storage and background jobs", Public: true,
+		Response: readiness{}, Other: map[int]any{http.StatusServiceUnavailable: readiness{}}},
+
+	// Documentation
+	{Method: "GET", Path: "/api/openapi.json", Tag: "Documentation", Summary: "This OpenAPI document", Public: true, Response: download{"application/json"}},
+	{Method: "GET", Path: "/api/docs", Tag: "Documentation", Summary: "Interactive API documentation", Public: true, Response: download{"text/html"}},
+
+	// Auth
+	{Method: "POST", Path: "/api/auth/register", Tag: "Auth", Summary: "Register a new user", Public: true,
+		Body: models.RegisterRequest{}, Status: http.StatusCreated, Response: models.AuthResponse{}},
+	{Method: "POST", Path: "/api/auth/login", Tag: "Auth", Summary: "Log in, or get a two-factor challenge", Public: true,
+		Body: models.LoginRequest{}, Response: oneOf{models.AuthResponse{}, models.TwoFactorRequiredResponse{}}},
+	{Method: "POST", Path: "/api/auth/login/2fa", Tag: "Auth", Summary: "Complete a login with a two-factor or recovery code", Public: true,
+		Body: models.TwoFactorLoginRequest{}, Response: models.AuthResponse{}},
+	{Method: "POST", Path: "/api/auth/logout", Tag: "Auth", Summary: "Revoke the session of the bearer token", Public: true},
+	{Method: "POST", Path: "/api/auth/refresh", Tag: "Auth", Summary: "Exchange a refresh token for new tokens", Public: true,
+		Body: models.RefreshRequest{}, Response: models.AuthResponse{}},
+	{Method: "POST", Path: "/api/auth/password/forgot", Tag: "Auth", Summary: "Email a password reset link", Public: true,
+		Body: models.ForgotPasswordRequest{}},
+	{Method: "POST", Path: "/api/auth/password/reset", Tag: "Auth", Summary: "Reset the password with an emailed token", Public: true,
+		Body: models.ResetPasswordRequest{}},
+	{Method: "POST", Path: "/api/auth/verify-email", Tag: "Auth", Summary: "Verify the email address with an emailed token", Public: true,
+		Body: models.VerifyEmailRequest{}},
+
+	// Profile
+	{Method: "GET", Path: "/api/auth/profile", Tag: "Profile", Summary: "Get the profile", Response: models.User{}},
+	{Method: "PUT", Path: "/api/auth/profile", Tag: "Profile", Summary: "Update the profile", Body: models.UpdateProfileRequest{}, Response: models.User{}},
+	{Method: "DELETE", Path: "/api/auth/profile", Tag: "Profile", Summary: "Schedule the account for deletion",
+		Body: models.DeleteAccountRequest{}, Status: http.StatusAccepted, Response: deletionScheduled{}},
+	{Method: "GET", Path: "/api/auth/profile/deletion", Tag: "Profile", Summary: "Get the scheduled account deletion", Response: models.AccountDeletion{}},
+	{Method: "DELETE", Path: "/api/auth/profile/deletion", Tag: "Profile", Summary: "Cancel the scheduled account deletion"},
+	{Method: "PUT", Path: "/api/auth/password", Tag: "Profile", Summary: "Change the password", Body: models.ChangePasswordRequest{}},
+	{Method: "POST", Path: "/api/auth/verify-email/resend", Tag: "Profile", Summary: "Resend the verification email"},
+
+	// Two-factor authentication
+	{Method: "POST", Path: "/api/auth/2fa/setup", Tag: "Two-factor authentication", Summary: "Start setting up two-factor authentication",
+		Response: models.TwoFactorSetupResponse{}},
+	{Method: "POST", Path: "/api/auth/2fa/enable", Tag: "Two-factor authentication", Summary: "Enable two-factor authentication",
+		Body: models.TwoFactorCodeRequest{}, Response: models.RecoveryCodesResponse{}},
+	{Method: "POST", Path: "/api/auth/2fa/disable", Tag: "Two-factor authentication", Summary: "Disable two-factor authentication",
+		Body: models.TwoFactorCodeRequest{}},
+	{Method: "POST", Path: "/api/auth/2fa/recovery-codes", Tag: "Two-factor authentication", Summary: "Replace the recovery codes",
+		Body: models.TwoFactorCodeRequest{}, Response: models.RecoveryCodesResponse{}},
+
+	// Sessions
+	{Method: "GET", Path: "/api/auth/sessions", Tag: "Sessions", Summary: "List active sessions", Response: []models.SessionResponse{}},
+	{Method: "DELETE", Path: "/api/auth/sessions", Tag: "Sessions", Summary: "Revoke all other sessions", Response: sessionsRevoked{}},
+	{Method: "DELETE", Path: "/api/auth/sessions/:id", Tag: "Sessions", Summary: "Revoke a session"},
+
+	// Personal access tokens
+	{Method: "GET", Path: "/api/tokens", Tag: "API tokens", Summary: "List personal access tokens", Response: []models.APIToken{}},
+	{Method: "POST", Path: "/api/tokens", Tag: "API tokens", Summary: "Create a personal access token",
+		Body: models.CreateAPITokenRequest{}, Status: http.StatusCreated, Response: models.CreateAPITokenResponse{}},
+	{Method: "DELETE", Path: "/api/tokens/:id", Tag: "API tokens", Summary: "Revoke a personal access token"},
+
+	// Households
+	{Method: "GET", Path: "/api/households", Tag: "Households", Summary: "List the user's households", Response: []models.Household{}},
+	{Method: "POST", Path: "/api/households", Tag: "Households", Summary: "Create a household",
+		Body: models.HouseholdRequest{}, Status: http.StatusCreated, Response: models.Household{}},
+	{Method: "POST", Path: "/api/households/invitations/accept", Tag: "Households", Summary: "Accept an invitation",
+		Body: models.AcceptInvitationRequest{}, Response: models.HouseholdMember{}},
+	{Method: "GET", Path: "/api/households/:id", Tag: "Households", Summary: "Get a household and its members", Response: models.HouseholdDetails{}},
+	{Method: "PUT", Path: "/api/households/:id", Tag: "Households", Summary: "Rename a household",
+		Body: models.HouseholdRequest{}, Response: models.Household{}},
+	{Method: "DELETE", Path: "/api/households/:id", Tag: "Households", Summary: "Delete a household"},
+	{Method: "PUT", Path: "/api/households/:id/members/:user_id", Tag: "Households", Summary: "Change a member's role",
+		Body: models.UpdateMemberRequest{}, Response: models.HouseholdMember{}},
+	{Method: "DELETE", Path: "/api/households/:id/members/:user_id", Tag: "Households", Summary: "Remove a member"},
+	{Method: "GET", Path: "/api/households/:id/invitations", Tag: "Households", Summary: "List pending invitations",
+		Response: []models.HouseholdInvitation{}},
+	{Method: "POST", Path: "/api/households/:id/invitations", Tag: "Households", Summary: "Invite someone by email",
+		Body: models.InviteMemberRequest{}, Status: http.StatusCreated, Response: models.HouseholdInvitation{}},
+	{Method: "DELETE", Path: "/api/households/:id/invitations/:invitation_id", Tag: "Households", Summary: "Revoke an invitation"},
+
+	// Accounts
+	{Method: "GET", Path: "/api/accounts", Tag: "Accounts", Summary: "List accounts, including accounts shared with the user",
+		Response: []models.Account{}},
+	{Method: "POST", Path: "/api/accounts", Tag: "Accounts", Summary: "Create an account",
+		Body: models.Account{}, Status: http.StatusCreated, Response: accountCreated{}},
+	{Method: "GET", Path: "/api/accounts/:id/shares", Tag: "Accounts", Summary: "List who an account is shared with",
+		Response: []models.AccountShare{}},
+	{Method: "POST", Path: "/api/accounts/:id/shares", Tag: "Accounts", Summary: "Share an account with another user",
+		Body: models.ShareAccountRequest{}, Response: models.AccountShare{}},
+	{Method: "DELETE", Path: "/api/accounts/:id/shares/:user_id", Tag: "Accounts", Summary: "Stop sharing an account with a user"},
+
+	// Categories
+	{Method: "GET", Path: "/api/categories", Tag: "Categories", Summary: "List categories", Response: []models.Category{}},
+	{Method: "POST", Path: "/api/categories", Tag: "Categories", Summary: "Create a category, or get the one with the same name",
+		Body: models.Category{}, Status: http.StatusCreated, Response: oneOf{categoryCreated{}, models.Category{}}},
+	{Method: "PUT", Path: "/api/categories/:id", Tag: "Categories", Summary: "Rename a category",
+		Body: models.UpdateCategoryRequest{}, Response: models.Category{}},
+	{Method: "DELETE", Path: "/api/categories/:id", Tag: "Categories", Summary: "Delete a category"},
+
+	// Transactions
+	{Method: "GET", Path: "/api/transactions", Tag: "Transactions", Summary: "List transactions",
+		Query: []any{models.TransactionFilter{}}, Response: []models.Transaction{}},
+	{Method: "POST", Path: "/api/transactions", Tag: "Transactions", Summary: "Record a transaction and update the account balance",
+		Body: models.Transaction{}, Status: http.StatusCreated, Response: transactionCreated{}},
+	{Method: "PUT", Path: "/api/transactions/:id", Tag: "Transactions", Summary: "Update a transaction and the affected balances",
+		Body: models.Transaction{}},
+	{Method: "DELETE", Path: "/api/transactions/:id", Tag: "Transactions", Summary: "Delete a transaction and revert its balance change"},
+
+	// Reports
+	{Method: "GET", Path: "/api/reports/summary", Tag: "Reports", Summary: "Income and expense totals by category and account",
+		Query: []any{models.ReportRange{}}, Response: models.SummaryReport{}},
+	{Method: "GET", Path: "/api/reports/cashflow", Tag: "Reports", Summary: "Income and expense per period",
+		Query: []any{models.ReportRange{}, models.CashFlowQuery{}}, Response: models.CashFlowReport{}},
+	{Method: "GET", Path: "/api/reports/networth", Tag: "Reports", Summary: "Assets and liabilities at the end of each period",
+		Query: []any{models.ReportRange{}, models.CashFlowQuery{}}, Response: models.NetWorthReport{}},
+
+	// Export and import
+	{Method: "GET", Path: "/api/export/transactions", Tag: "Export and import", Summary: "Download transactions",
+		Query: []any{exportFormat, models.TransactionFilter{}}, Response: exportFiles},
+	{Method: "GET", Path: "/api/export/accounts", Tag: "Export and import", Summary: "Download accounts",
+		Query: []any{exportFormat}, Response: exportFiles},
+	{Method: "GET", Path: "/api/export/categories", Tag: "Export and import", Summary: "Download categories",
+		Query: []any{exportFormat}, Response: exportFiles},
+	{Method: "GET", Path: "/api/export/archive", Tag: "Export and import", Summary: "Download a full archive of the workspace",
+		Response: models.Archive{}},
+	{Method: "POST", Path: "/api/import/archive", Tag: "Export and import", Summary: "Restore an archive into the workspace",
+		Query: []any{dryRun}, Body: models.Archive{}, Upload: true, Response: models.RestoreResult{},
+		Other: map[int]any{http.StatusCreated: models.RestoreResult{}}},
+}
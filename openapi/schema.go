@@ -0,0 +1,227 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is an OpenAPI 3.0 schema object, limited to what the API's
+// models need.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+// generator turns Go types into schemas. Named structs become components
+// referenced by $ref; field constraints come from the binding tags the
+// handlers validate with.
+type generator struct {
+	components map[string]*Schema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *generator) schema(t reflect.Type) *Schema {
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := g.schema(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	case reflect.Struct:
+		name := t.Name()
+		if name == "" {
+			return g.object(t)
+		}
+		// The documentation-only types of this package are unexported
+		name = strings.ToUpper(name[:1]) + name[1:]
+		if _, ok := g.components[name]; !ok {
+			g.components[name] = nil // break cycles
+			g.components[name] = g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	}
+	return &Schema{}
+}
+
+// object describes a struct by its JSON fields. Embedded structs are
+// flattened, as encoding/json does.
+func (g *generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			embedded := g.object(f.Type)
+			for name, prop := range embedded.Properties {
+				s.Properties[name] = prop
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		prop := g.schema(f.Type)
+		if applyRules(prop, f.Tag.Get("binding")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+	return s
+}
+
+// applyRules copies validator rules such as oneof, gt or max onto s and
+// reports whether the field is required. Rules after "dive" apply to the
+// items of a list.
+func applyRules(s *Schema, binding string) (required bool) {
+	target := s
+	for _, rule := range strings.Split(binding, ",") {
+		tag, param, _ := strings.Cut(rule, "=")
+		switch tag {
+		case "required":
+			if target == s {
+				required = true
+			}
+		case "dive":
+			if target.Items == nil {
+				return required
+			}
+			target = target.Items
+		case "oneof":
+			target.Enum = strings.Fields(param)
+		case "email":
+			target.Format = "email"
+		case "uuid":
+			target.Format = "uuid"
+		case "url":
+			target.Format = "uri"
+		case "datetime":
+			if param == "2006-01-02" {
+				target.Format = "date"
+			} else {
+				target.Pattern = datePattern(param)
+			}
+		case "iso4217":
+			target.Pattern = "^[A-Z]{3}$"
+		case "timezone":
+			target.Description = "IANA time zone, e.g. Asia/Jakarta"
+		case "bcp47_language_tag":
+			target.Description = "BCP 47 language tag, e.g. id-ID"
+		case "notblank":
+			target.Pattern = `\S`
+		case "min", "max", "len":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			limit(target, tag, n)
+		case "gt", "gte", "lt", "lte":
+			n, err := strconv.ParseFloat(param, 64)
+			if err != nil {
+				continue
+			}
+			switch tag {
+			case "gt", "gte":
+				target.Minimum, target.ExclusiveMinimum = &n, tag == "gt"
+			default:
+				target.Maximum, target.ExclusiveMaximum = &n, tag == "lt"
+			}
+		}
+	}
+	return required
+}
+
+// limit applies min, max or len as a length, item count or value bound,
+// depending on the type, like the validator does.
+func limit(s *Schema, tag string, n int) {
+	f := float64(n)
+	switch s.Type {
+	case "string":
+		if tag != "max" {
+			s.MinLength = &n
+		}
+		if tag != "min" {
+			s.MaxLength = &n
+		}
+	case "array":
+		if tag != "max" {
+			s.MinItems = &n
+		}
+		if tag != "min" {
+			s.MaxItems = &n
+		}
+	default:
+		if tag != "max" {
+			s.Minimum = &f
+		}
+		if tag != "min" {
+			s.Maximum = &f
+		}
+	}
+}
+
+// datePattern turns a Go time layout made of digits into a regular
+// expression, e.g. 2006-01 into ^\d{4}-\d{2}$.
+func datePattern(layout string) string {
+	r := strings.NewReplacer("2006", `\d{4}`, "01", `\d{2}`, "02", `\d{2}`, "15", `\d{2}`, "04", `\d{2}`, "05", `\d{2}`)
+	return "^" + r.Replace(layout) + "$"
+}
+
+// queryParameters describes a struct bound with ShouldBindQuery by its
+// form tags.
+func (g *generator) queryParameters(t reflect.Type) []*Parameter {
+	var params []*Parameter
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("form"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		s := g.schema(f.Type)
+		required := applyRules(s, f.Tag.Get("binding"))
+		params = append(params, &Parameter{Name: name, In: "query", Required: required, Schema: s})
+	}
+	return params
+}
@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Lockout locks a key (an email address) after repeated failures. Each
+// lockout within Memory doubles the previous duration, up to MaxDuration.
+type Lockout struct {
+	Store        Store
+	MaxFailures  int
+	Window       time.Duration
+	BaseDuration time.Duration
+	MaxDuration  time.Duration
+	Memory       time.Duration
+}
+
+// Locked reports whether key is locked and for how much longer.
+func (l *Lockout) Locked(ctx context.Context, key string) (time.Duration, error) {
+	n, ttl, err := l.Store.Get(ctx, "locked:"+key)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	return ttl, nil
+}
+
+// Fail records a failure and returns the lockout duration if this failure
+// triggered one.
+func (l *Lockout) Fail(ctx context.Context, key string) (time.Duration, error) {
+	failures, _, err := l.Store.Incr(ctx, "failures:"+key, l.Window)
+	if err != nil || failures < l.MaxFailures {
+		return 0, err
+	}
+	if err := l.Store.Reset(ctx, "failures:"+key); err != nil {
+		return 0, err
+	}
+
+	level, _, err := l.Store.Incr(ctx, "lockouts:"+key, l.Memory)
+	if err != nil {
+		return 0, err
+	}
+	d := l.BaseDuration
+	for i := 1; i < level && d < l.MaxDuration; i++ {
+		d *= 2
+	}
+	d = min(d, l.MaxDuration)
+
+	if _, _, err := l.Store.Incr(ctx, "locked:"+key, d); err != nil {
+		return 0, err
+	}
+	return d, nil
+}
+
+// Clear forgets everything about key, including earlier lockouts.
+func (l *Lockout) Clear(ctx context.Context, key string) error {
+	for _, prefix := range []string{"failures:", "lockouts:", "locked:"} {
+		if err := l.Store.Reset(ctx, prefix+key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Succeed clears the failure count after a successful attempt. Earlier
+// lockouts are still remembered for the next escalation.
+func (l *Lockout) Succeed(ctx context.Context, key string) error {
+	return l.Store.Reset(ctx, "failures:"+key)
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestLockout() *Lockout {
+	return &Lockout{
+		Store:        NewMemoryStore(),
+		MaxFailures:  3,
+		Window:       time.Minute,
+		BaseDuration: time.Minute,
+		MaxDuration:  5 * time.Minute,
+		Memory:       time.Hour,
+	}
+}
+
+// failUntilLocked fails until a lockout starts and returns its duration.
+func failUntilLocked(t *testing.T, l *Lockout, key string) time.Duration {
+	t.Helper()
+	ctx := context.Background()
+	for i := 1; i <= l.MaxFailures; i++ {
+		d, err := l.Fail(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (d > 0) != (i == l.MaxFailures) {
+			t.Fatalf("failure %d locked for %v", i, d)
+		}
+		if d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func TestLockoutDoubles(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLockout()
+
+	// Each lockout within Memory doubles, up to MaxDuration
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		if got := failUntilLocked(t, l, "a@example.com"); got != want {
+			t.Fatalf("lockout = %v, want %v", got, want)
+		}
+		retryAfter, err := l.Locked(ctx, "a@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if retryAfter <= 0 || retryAfter > want {
+			t.Fatalf("Locked = %v, want up to %v", retryAfter, want)
+		}
+		// Let the lockout run out
+		l.Store.Reset(ctx, "locked:a@example.com")
+	}
+
+	if retryAfter, _ := l.Locked(ctx, "b@example.com"); retryAfter != 0 {
+		t.Errorf("other key locked for %v", retryAfter)
+	}
+}
+
+func TestLockoutReset(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		reset func(l *Lockout, ctx context.Context, key string) error
+		// next is the lockout after the reset
+		next time.Duration
+	}{
+		{"succeed keeps the escalation", (*Lockout).Succeed, 2 * time.Minute},
+		{"clear forgets earlier lockouts", (*Lockout).Clear, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLockout()
+			const key = "a@example.com"
+			failUntilLocked(t, l, key)
+			l.Store.Reset(ctx, "locked:"+key)
+
+			// Failures before the reset no longer count
+			l.Fail(ctx, key)
+			if err := tt.reset(l, ctx, key); err != nil {
+				t.Fatal(err)
+			}
+			if got := failUntilLocked(t, l, key); got != tt.next {
+				t.Errorf("lockout after reset = %v, want %v", got, tt.next)
+			}
+		})
+	}
+}
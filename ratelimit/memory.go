@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type counter struct {
+	count   int
+	expires time.Time
+}
+
+// MemoryStore is a Store for a single instance. Expired counters are
+// swept periodically so the map does not grow without bound.
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]*counter
+	lastSweep time.Time
+}
+
+const sweepInterval = time.Minute
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: map[string]*counter{}, lastSweep: time.Now()}
+}
+
+func (m *MemoryStore) Incr(ctx context.Context, key string, window time.Duration) (int, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+
+	c, ok := m.counters[key]
+	if !ok || !now.Before(c.expires) {
+		c = &counter{expires: now.Add(window)}
+		m.counters[key] = c
+	}
+	c.count++
+	return c.count, c.expires.Sub(now), nil
+}
+
+func (m *MemoryStore) Get(ctx context.Context, key string) (int, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	c, ok := m.counters[key]
+	if !ok || !now.Before(c.expires) {
+		return 0, 0, nil
+	}
+	return c.count, c.expires.Sub(now), nil
+}
+
+func (m *MemoryStore) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	delete(m.counters, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, c := range m.counters {
+		if !now.Before(c.expires) {
+			delete(m.counters, key)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		run       func(m *MemoryStore)
+		wantCount int
+	}{
+		{"counts within the window", func(m *MemoryStore) {
+			m.Incr(ctx, "k", time.Minute)
+			m.Incr(ctx, "k", time.Minute)
+		}, 2},
+		{"reset removes the counter", func(m *MemoryStore) {
+			m.Incr(ctx, "k", time.Minute)
+			m.Reset(ctx, "k")
+		}, 0},
+		{"expired counter is gone", func(m *MemoryStore) {
+			m.Incr(ctx, "k", time.Minute)
+			m.counters["k"].expires = time.Now().Add(-time.Second)
+		}, 0},
+		{"expired counter starts a new window", func(m *MemoryStore) {
+			m.Incr(ctx, "k", time.Minute)
+			m.Incr(ctx, "k", time.Minute)
+			m.counters["k"].expires = time.Now().Add(-time.Second)
+			m.Incr(ctx, "k", time.Minute)
+		}, 1},
+		{"keys are separate", func(m *MemoryStore) {
+			m.Incr(ctx, "other", time.Minute)
+		}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemoryStore()
+			tt.run(m)
+
+			n, ttl, err := m.Get(ctx, "k")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != tt.wantCount {
+				t.Errorf("count = %d, want %d", n, tt.wantCount)
+			}
+			if n > 0 && (ttl <= 0 || ttl > time.Minute) {
+				t.Errorf("ttl = %v, want within the window", ttl)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryStore()
+	m.Incr(ctx, "old", time.Minute)
+	m.Incr(ctx, "live", time.Hour)
+	m.counters["old"].expires = time.Now().Add(-time.Second)
+	m.lastSweep = time.Now().Add(-2 * sweepInterval)
+
+	m.Incr(ctx, "new", time.Minute)
+
+	if _, ok := m.counters["old"]; ok {
+		t.Error("expired counter was not swept")
+	}
+	if _, ok := m.counters["live"]; !ok {
+		t.Error("live counter was swept")
+	}
+}
@@ -0,0 +1,21 @@
+// Package ratelimit counts attempts per key in fixed time windows. The
+// Store interface lets several API instances share their counters.
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps expiring counters.
+type Store interface {
+	// Incr adds one to key and returns the new count and the time left
+	// until the counter resets. A missing or expired counter starts a new
+	// window of the given length.
+	Incr(ctx context.Context, key string, window time.Duration) (int, time.Duration, error)
+	// Get returns the current count and time left, or zero values when
+	// the counter does not exist or has expired.
+	Get(ctx context.Context, key string) (int, time.Duration, error)
+	// Reset removes the counter.
+	Reset(ctx context.Context, key string) error
+}
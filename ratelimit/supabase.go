@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/leo140803/finance-app-backend/db"
+)
+
+// SupabaseStore keeps counters in the rate_limits table so every instance
+// sees the same numbers. Increments go through the rate_limit_incr
+// function to stay atomic (see README for the SQL).
+type SupabaseStore struct {
+	DB *db.Client
+}
+
+type supabaseCounter struct {
+	Count     int       `json:"count"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (s *SupabaseStore) Incr(ctx context.Context, key string, window time.Duration) (int, time.Duration, error) {
+	var rows []supabaseCounter
+	params := map[string]any{"p_key": key, "p_ttl_seconds": int(window.Seconds())}
+	if err := s.DB.RPC("rate_limit_incr", params).Execute(ctx, &rows); err != nil {
+		return 0, 0, err
+	}
+	if len(rows) == 0 {
+		return 0, 0, nil
+	}
+	return rows[0].Count, time.Until(rows[0].ExpiresAt), nil
+}
+
+func (s *SupabaseStore) Get(ctx context.Context, key string) (int, time.Duration, error) {
+	var rows []supabaseCounter
+	err := s.DB.From("rate_limits").Select("count,expires_at").
+		Eq("key", key).
+		Gt("expires_at", time.Now().UTC().Format(time.RFC3339)).
+		Execute(ctx, &rows)
+	if err != nil || len(rows) == 0 {
+		return 0, 0, err
+	}
+	return rows[0].Count, time.Until(rows[0].ExpiresAt), nil
+}
+
+func (s *SupabaseStore) Reset(ctx context.Context, key string) error {
+	return s.DB.From("rate_limits").Delete().Eq("key", key).Execute(ctx, nil)
+}
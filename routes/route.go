@@ -1,48 +1,123 @@
 package routes
 
 import (
+	"log/slog"
+	"strings"
+	"time"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/config"
 	"github.com/leo140803/finance-app-backend/handlers"
+	"github.com/leo140803/finance-app-backend/jobs"
 	"github.com/leo140803/finance-app-backend/middleware"
+	"github.com/leo140803/finance-app-backend/tracing"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-func SetupRouter() *gin.Engine {
-	r := gin.Default()
+// SetupRouter builds the API. The scheduler is only read by the readiness
+// check. Every /api route must be listed in the OpenAPI document; the
+// routes test fails when they disagree.
+func SetupRouter(scheduler *jobs.Scheduler) *gin.Engine {
+	r := gin.New()
+	// Client IPs key the rate limits, so forwarded headers are only read
+	// from known proxies. nil, the default, trusts none.
+	if err := r.SetTrustedProxies(config.Server.TrustedProxies); err != nil {
+		slog.Error("invalid trusted proxies, ignoring forwarded headers", "error", err)
+		r.SetTrustedProxies(nil)
+	}
+	r.Use(otelgin.Middleware(tracing.ServiceName(), otelgin.WithGinFilter(func(c *gin.Context) bool {
+		return c.FullPath() != "/metrics" && !strings.HasPrefix(c.FullPath(), "/api/health")
+	})))
+	r.Use(middleware.RequestID(), middleware.Logger(), middleware.Metrics(), middleware.Recovery())
+	r.Use(middleware.Timeout(config.Timeouts.Request, config.Timeouts.BulkRequest, "/api/export/", "/api/import/"))
 
 	// CORS middleware configuration
 	corsConfig := cors.DefaultConfig()
-	corsConfig.AllowOrigins = []string{"http://localhost:3000", "http://127.0.0.1:3000", "http://localhost:3001", "https://fe-duitku-git-main-leonardo-nickholas-andriantos-projects.vercel.app"}
+	corsConfig.AllowOrigins = config.Server.CORSOrigins
 	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", middleware.HouseholdHeader, middleware.RequestIDHeader}
+	corsConfig.ExposeHeaders = []string{middleware.RequestIDHeader}
 	corsConfig.AllowCredentials = true
 	
 	r.Use(cors.New(corsConfig))
 
+	// Prometheus scrape endpoint
+	if config.Features.Metrics {
+		r.GET("/metrics", middleware.MetricsAuth(config.Metrics.Token), gin.WrapH(promhttp.Handler()))
+	}
+
+	features := config.Features
+
 	api := r.Group("/api")
 	{
 		//HEALTH CHECK
-		api.GET("/health", func(c *gin.Context) {
-			c.JSON(200, gin.H{
-				"status":  "ok",
-				"message": "service is healthy",
-			})
-		})
+		api.GET("/health", handlers.Liveness)
+		api.GET("/health/live", handlers.Liveness)
+		api.GET("/health/ready", handlers.Readiness(scheduler))
+		//API DOCUMENTATION
+		api.GET("/openapi.json", handlers.OpenAPISpec)
+		api.GET("/docs", handlers.APIDocs)
 		// Public routes (no authentication required)
-		api.POST("/auth/register", handlers.Register)
-		api.POST("/auth/login", handlers.Login)
+		authLimit := middleware.RateLimit("auth", 20, time.Minute)
+		mailLimit := middleware.RateLimit("mail", 5, 15*time.Minute)
+		api.POST("/auth/register", middleware.Feature(features.Registration), authLimit, handlers.Register)
+		api.POST("/auth/login", authLimit, handlers.Login)
+		api.POST("/auth/login/2fa", authLimit, handlers.LoginTwoFactor)
 		api.POST("/auth/logout", handlers.Logout)
+		api.POST("/auth/refresh", authLimit, handlers.RefreshToken)
+		api.POST("/auth/password/forgot", mailLimit, handlers.ForgotPassword)
+		api.POST("/auth/password/reset", authLimit, handlers.ResetPassword)
+		api.POST("/auth/verify-email", authLimit, handlers.VerifyEmail)
 
 		// Protected routes (authentication required)
 		protected := api.Group("")
-		protected.Use(middleware.AuthMiddleware())
+		protected.Use(middleware.AuthMiddleware(), middleware.Workspace())
 		{
 			// User profile
 			protected.GET("/auth/profile", handlers.GetProfile)
+			protected.PUT("/auth/profile", handlers.UpdateProfile)
+			protected.DELETE("/auth/profile", handlers.DeleteAccount)
+			protected.GET("/auth/profile/deletion", handlers.GetAccountDeletion)
+			protected.DELETE("/auth/profile/deletion", handlers.CancelAccountDeletion)
+			protected.PUT("/auth/password", handlers.ChangePassword)
+			protected.POST("/auth/verify-email/resend", mailLimit, handlers.ResendVerification)
+			protected.POST("/auth/2fa/setup", middleware.Feature(features.TwoFactor), handlers.SetupTwoFactor)
+			protected.POST("/auth/2fa/enable", middleware.Feature(features.TwoFactor), handlers.EnableTwoFactor)
+			protected.POST("/auth/2fa/disable", handlers.DisableTwoFactor)
+			protected.POST("/auth/2fa/recovery-codes", handlers.RegenerateRecoveryCodes)
+			protected.GET("/auth/sessions", handlers.GetSessions)
+			protected.DELETE("/auth/sessions", handlers.RevokeOtherSessions)
+			protected.DELETE("/auth/sessions/:id", handlers.RevokeSession)
+
+			// Personal access tokens
+			tokens := protected.Group("/tokens", middleware.Feature(features.APITokens))
+			tokens.GET("", handlers.GetAPITokens)
+			tokens.POST("", handlers.CreateAPIToken)
+			tokens.DELETE("/:id", handlers.RevokeAPIToken)
+
+			// Households
+			households := protected.Group("/households", middleware.Feature(features.Households))
+			households.GET("", handlers.GetHouseholds)
+			households.POST("", handlers.CreateHousehold)
+			households.POST("/invitations/accept", handlers.AcceptHouseholdInvitation)
+			households.GET("/:id", handlers.GetHousehold)
+			households.PUT("/:id", handlers.UpdateHousehold)
+			households.DELETE("/:id", handlers.DeleteHousehold)
+			households.PUT("/:id/members/:user_id", handlers.UpdateHouseholdMember)
+			households.DELETE("/:id/members/:user_id", handlers.RemoveHouseholdMember)
+			households.GET("/:id/invitations", handlers.GetHouseholdInvitations)
+			households.POST("/:id/invitations", handlers.InviteHouseholdMember)
+			households.DELETE("/:id/invitations/:invitation_id", handlers.RevokeHouseholdInvitation)
 
 			// Accounts
 			protected.GET("/accounts", handlers.GetAccounts)
 			protected.POST("/accounts", handlers.CreateAccount)
+			shares := protected.Group("/accounts/:id/shares", middleware.Feature(features.AccountSharing))
+			shares.GET("", handlers.GetAccountShares)
+			shares.POST("", handlers.ShareAccount)
+			shares.DELETE("/:user_id", handlers.UnshareAccount)
 
 			// Categories
 			protected.GET("/categories", handlers.GetCategories)
@@ -56,6 +131,19 @@ func SetupRouter() *gin.Engine {
 			protected.POST("/transactions", handlers.CreateTransaction)
 			protected.PUT("/transactions/:id", handlers.UpdateTransaction)
 			protected.DELETE("/transactions/:id", handlers.DeleteTransaction)
+
+			// Reports
+			protected.GET("/reports/summary", handlers.GetSummaryReport)
+			protected.GET("/reports/cashflow", handlers.GetCashFlowReport)
+			protected.GET("/reports/networth", handlers.GetNetWorthReport)
+
+			// Export
+			export := protected.Group("/export", middleware.Feature(features.Export))
+			export.GET("/transactions", handlers.ExportTransactions)
+			export.GET("/accounts", handlers.ExportAccounts)
+			export.GET("/categories", handlers.ExportCategories)
+			export.GET("/archive", handlers.ExportArchive)
+			protected.POST("/import/archive", middleware.Feature(features.Import), handlers.RestoreArchive)
 		}
 	}
 
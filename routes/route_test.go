@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/leo140803/finance-app-backend/config"
+	"github.com/leo140803/finance-app-backend/jobs"
+	"github.com/leo140803/finance-app-backend/openapi"
+)
+
+// TestRoutesDocumented fails when a route is added without an entry in
+// the OpenAPI document, or an operation is documented without a route.
+func TestRoutesDocumented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.Server = config.ServerConfig{CORSOrigins: []string{"http://localhost:3000"}}
+	config.Features = config.FeatureConfig{
+		Registration:   true,
+		TwoFactor:      true,
+		Households:     true,
+		AccountSharing: true,
+		APITokens:      true,
+		Export:         true,
+		Import:         true,
+		Metrics:        true,
+	}
+
+	r := SetupRouter(jobs.NewScheduler())
+	if err := openapi.Check(r.Routes()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,73 @@
+// Package tracing sets up OpenTelemetry tracing for HTTP requests and
+// storage calls.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultServiceName is used until Init sets another.
+const DefaultServiceName = "duitku-backend"
+
+var serviceName = DefaultServiceName
+
+// ServiceName returns the service name reported on spans.
+func ServiceName() string {
+	return serviceName
+}
+
+// Tracer returns the tracer used by this application's packages.
+func Tracer() trace.Tracer {
+	return otel.Tracer("github.com/leo140803/finance-app-backend")
+}
+
+// Init installs the tracer provider for the named exporter: "otlp" (OTLP
+// over HTTP, configured with the standard OTEL_EXPORTER_OTLP_* variables),
+// "stdout" (pretty printed spans, for local use) or "none". The returned
+// function flushes pending spans and must be called before the process
+// exits.
+func Init(ctx context.Context, exporterName, service string) (func(context.Context) error, error) {
+	if service != "" {
+		serviceName = service
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch exporterName {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q (use otlp, stdout or none)", exporterName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName())))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}